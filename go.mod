@@ -3,6 +3,10 @@ module github.com/JadedPigeon/Gator
 go 1.24.3
 
 require (
-	github.com/google/uuid v1.6.0 // indirect
-	github.com/lib/pq v1.10.9 // indirect
+	github.com/andybalholm/brotli v1.2.6
+	github.com/google/uuid v1.6.0
+	github.com/lib/pq v1.10.9
+	golang.org/x/crypto v0.45.0
 )
+
+require golang.org/x/sys v0.38.0 // indirect
@@ -0,0 +1,168 @@
+// Package api serves Gator's data as JSON over HTTP.
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/JadedPigeon/Gator/internal/auth"
+	"github.com/JadedPigeon/Gator/internal/database"
+	"github.com/lib/pq"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// Server answers API requests. Every request must carry one of the user's
+// API tokens as "Authorization: Bearer <token>" and acts as that user.
+type Server struct {
+	DB  *database.Queries
+	Log *slog.Logger
+}
+
+// logger returns Log, or the default logger if it isn't set.
+func (srv *Server) logger() *slog.Logger {
+	if srv.Log != nil {
+		return srv.Log
+	}
+	return slog.Default()
+}
+
+// Handler returns the API routes, under /api/ and /fever/.
+func (srv *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/users", srv.withUser(srv.handleUsers))
+	mux.HandleFunc("GET /api/feeds", srv.withUser(srv.handleFeeds))
+	mux.HandleFunc("GET /api/follows", srv.withUser(srv.handleFollows))
+	mux.HandleFunc("POST /api/follows", srv.withUser(srv.handleFollow))
+	mux.HandleFunc("DELETE /api/follows/{feedID}", srv.withUser(srv.handleUnfollow))
+	mux.HandleFunc("GET /api/posts", srv.withUser(srv.handlePosts))
+	mux.HandleFunc("PUT /api/posts/{postID}/read", srv.withUser(srv.handleMarkRead(true)))
+	mux.HandleFunc("DELETE /api/posts/{postID}/read", srv.withUser(srv.handleMarkRead(false)))
+	// Feed readers can't send headers, so this one also takes ?token=
+	mux.HandleFunc("GET /api/feed", srv.withUserOrQueryToken(srv.handleFeed))
+	// Fever clients authenticate with their own api_key parameter
+	mux.HandleFunc("/fever/", srv.handleFever)
+	return mux
+}
+
+type userHandler func(w http.ResponseWriter, r *http.Request, user database.User)
+
+// withUser authenticates the request before calling h, like
+// cli.MiddlewareLoggedIn does for commands.
+func (srv *Server) withUser(h userHandler) http.HandlerFunc {
+	return srv.authenticate(h, false)
+}
+
+// withUserOrQueryToken is withUser for URLs that are pasted into other
+// apps, where the token has to be part of the URL.
+func (srv *Server) withUserOrQueryToken(h userHandler) http.HandlerFunc {
+	return srv.authenticate(h, true)
+}
+
+func (srv *Server) authenticate(h userHandler, allowQuery bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok && allowQuery {
+			token = r.URL.Query().Get("token")
+		}
+		if token == "" {
+			unauthorized(w, "missing API token")
+			return
+		}
+		user, err := srv.UserForToken(r.Context(), token)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				unauthorized(w, "invalid API token")
+				return
+			}
+			srv.internalError(w, r, err)
+			return
+		}
+		h(w, r, user)
+	}
+}
+
+// UserForToken returns the owner of an API token and records that the token
+// was used. It returns sql.ErrNoRows for unknown or revoked tokens.
+func (srv *Server) UserForToken(ctx context.Context, token string) (database.User, error) {
+	hash := auth.HashToken(token)
+	user, err := srv.DB.GetUserByAPIToken(ctx, hash)
+	if err != nil {
+		return database.User{}, err
+	}
+	if err := srv.DB.TouchAPIToken(ctx, hash); err != nil {
+		return database.User{}, err
+	}
+	return user, nil
+}
+
+func unauthorized(w http.ResponseWriter, msg string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="gator"`)
+	writeError(w, http.StatusUnauthorized, msg)
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, errorResponse{Error: msg})
+}
+
+// internalError reports a failure without leaking database details to the
+// client. A cancelled request gets no response body at all.
+func (srv *Server) internalError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(r.Context().Err(), context.Canceled) {
+		return
+	}
+	srv.logger().Error("API error", "method", r.Method, "path", r.URL.Path, "error", err)
+	writeError(w, http.StatusInternalServerError, "internal server error")
+}
+
+// pageParams reads limit and offset from the query string.
+func pageParams(r *http.Request) (limit, offset int, err error) {
+	limit = defaultPageSize
+	if s := r.URL.Query().Get("limit"); s != "" {
+		limit, err = strconv.Atoi(s)
+		if err != nil || limit <= 0 || limit > maxPageSize {
+			return 0, 0, fmt.Errorf("limit must be between 1 and %d", maxPageSize)
+		}
+	}
+	if s := r.URL.Query().Get("offset"); s != "" {
+		offset, err = strconv.Atoi(s)
+		if err != nil || offset < 0 {
+			return 0, 0, errors.New("offset must be a non-negative integer")
+		}
+	}
+	return limit, offset, nil
+}
+
+// pqCode returns the Postgres error code of err, if it has one.
+func pqCode(err error) pq.ErrorCode {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code
+	}
+	return ""
+}
+
+const (
+	foreignKeyViolation = "23503"
+	uniqueViolation     = "23505"
+)
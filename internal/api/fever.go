@@ -0,0 +1,328 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JadedPigeon/Gator/internal/auth"
+	"github.com/JadedPigeon/Gator/internal/database"
+)
+
+// Fever API, version 3. Clients post api_key=md5("name:password") to
+// /fever/?api along with what they want, e.g. &items&since_id=10. Every
+// followed feed is put in one group, since Gator has no folders.
+const (
+	feverAPIVersion = 3
+	feverGroupID    = 1
+	feverMaxIDs     = 50
+)
+
+type feverGroup struct {
+	ID    int    `json:"id"`
+	Title string `json:"title"`
+}
+
+type feverFeedsGroup struct {
+	GroupID int    `json:"group_id"`
+	FeedIDs string `json:"feed_ids"`
+}
+
+type feverFeed struct {
+	ID                int64  `json:"id"`
+	FaviconID         int    `json:"favicon_id"`
+	Title             string `json:"title"`
+	URL               string `json:"url"`
+	SiteURL           string `json:"site_url"`
+	IsSpark           int    `json:"is_spark"`
+	LastUpdatedOnTime int64  `json:"last_updated_on_time"`
+}
+
+type feverItem struct {
+	ID            int64  `json:"id"`
+	FeedID        int64  `json:"feed_id"`
+	Title         string `json:"title"`
+	Author        string `json:"author"`
+	HTML          string `json:"html"`
+	URL           string `json:"url"`
+	IsSaved       int    `json:"is_saved"`
+	IsRead        int    `json:"is_read"`
+	CreatedOnTime int64  `json:"created_on_time"`
+}
+
+func (srv *Server) handleFever(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid form data")
+		return
+	}
+	if !r.Form.Has("api") {
+		writeError(w, http.StatusBadRequest, "not a Fever API request")
+		return
+	}
+	resp := map[string]any{
+		"api_version": feverAPIVersion,
+		"auth":        0,
+	}
+	// Fever reports bad credentials in the body, with a 200
+	keyHash := auth.HashToken(strings.ToLower(r.Form.Get("api_key")))
+	user, err := srv.DB.GetUserByFeverKey(r.Context(), keyHash)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			writeJSON(w, http.StatusOK, resp)
+			return
+		}
+		srv.internalError(w, r, err)
+		return
+	}
+	resp["auth"] = 1
+
+	if r.Form.Has("mark") {
+		if err := srv.feverMark(r, user, resp); err != nil {
+			srv.feverError(w, r, err)
+			return
+		}
+	}
+
+	feeds, err := srv.DB.GetFeverFeeds(r.Context(), user.ID)
+	if err != nil {
+		srv.internalError(w, r, err)
+		return
+	}
+	var lastRefreshed int64
+	for _, f := range feeds {
+		if f.LastFetchedAt.Valid && f.LastFetchedAt.Time.Unix() > lastRefreshed {
+			lastRefreshed = f.LastFetchedAt.Time.Unix()
+		}
+	}
+	resp["last_refreshed_on_time"] = lastRefreshed
+
+	if r.Form.Has("groups") || r.Form.Has("feeds") {
+		ids := make([]string, 0, len(feeds))
+		for _, f := range feeds {
+			ids = append(ids, strconv.FormatInt(f.Seq, 10))
+		}
+		resp["feeds_groups"] = []feverFeedsGroup{{GroupID: feverGroupID, FeedIDs: strings.Join(ids, ",")}}
+	}
+	if r.Form.Has("groups") {
+		resp["groups"] = []feverGroup{{ID: feverGroupID, Title: "All"}}
+	}
+	if r.Form.Has("feeds") {
+		out := make([]feverFeed, 0, len(feeds))
+		for _, f := range feeds {
+			feed := feverFeed{ID: f.Seq, Title: f.Name, URL: f.Url, SiteURL: f.Url}
+			if f.LastFetchedAt.Valid {
+				feed.LastUpdatedOnTime = f.LastFetchedAt.Time.Unix()
+			}
+			out = append(out, feed)
+		}
+		resp["feeds"] = out
+	}
+	if r.Form.Has("favicons") {
+		resp["favicons"] = []struct{}{}
+	}
+	if r.Form.Has("links") {
+		resp["links"] = []struct{}{}
+	}
+	if r.Form.Has("items") {
+		if err := srv.feverItems(r, user, resp); err != nil {
+			srv.feverError(w, r, err)
+			return
+		}
+	}
+	if r.Form.Has("unread_item_ids") {
+		if err := srv.addUnreadIDs(r, user, resp); err != nil {
+			srv.internalError(w, r, err)
+			return
+		}
+	}
+	if r.Form.Has("saved_item_ids") {
+		if err := srv.addSavedIDs(r, user, resp); err != nil {
+			srv.internalError(w, r, err)
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// feverRequestError is a problem with the client's request. It is sent back
+// with its status, while any other error is logged as a server error.
+type feverRequestError struct {
+	status int
+	msg    string
+}
+
+func (e *feverRequestError) Error() string { return e.msg }
+
+func badFeverRequest(status int, msg string) error {
+	return &feverRequestError{status: status, msg: msg}
+}
+
+func (srv *Server) feverError(w http.ResponseWriter, r *http.Request, err error) {
+	var reqErr *feverRequestError
+	if errors.As(err, &reqErr) {
+		writeError(w, reqErr.status, reqErr.msg)
+		return
+	}
+	srv.internalError(w, r, err)
+}
+
+// feverItems adds up to 50 items, selected by since_id, max_id or with_ids.
+func (srv *Server) feverItems(r *http.Request, user database.User, resp map[string]any) error {
+	params := database.GetFeverItemsParams{UserID: user.ID}
+	if s := r.Form.Get("since_id"); s != "" {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return badFeverRequest(http.StatusBadRequest, "invalid since_id")
+		}
+		params.SinceID = sql.NullInt64{Int64: n, Valid: true}
+	}
+	if s := r.Form.Get("max_id"); s != "" {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return badFeverRequest(http.StatusBadRequest, "invalid max_id")
+		}
+		params.MaxID = sql.NullInt64{Int64: n, Valid: true}
+	}
+	if s := r.Form.Get("with_ids"); s != "" {
+		for _, part := range strings.Split(s, ",") {
+			n, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+			if err != nil {
+				return badFeverRequest(http.StatusBadRequest, "invalid with_ids")
+			}
+			params.WithIds = append(params.WithIds, n)
+		}
+		if len(params.WithIds) > feverMaxIDs {
+			params.WithIds = params.WithIds[:feverMaxIDs]
+		}
+	}
+	rows, err := srv.DB.GetFeverItems(r.Context(), params)
+	if err != nil {
+		return fmt.Errorf("error retrieving items: %v", err)
+	}
+	items := make([]feverItem, 0, len(rows))
+	for _, row := range rows {
+		items = append(items, feverItem{
+			ID:            row.Seq,
+			FeedID:        row.FeedSeq,
+			Title:         row.Title,
+			Author:        row.Author.String,
+			HTML:          row.Description.String,
+			URL:           row.Url,
+			IsSaved:       boolInt(row.Starred),
+			IsRead:        boolInt(row.Read),
+			CreatedOnTime: row.PublishedAt.Unix(),
+		})
+	}
+	total, err := srv.DB.CountFeverItems(r.Context(), user.ID)
+	if err != nil {
+		return fmt.Errorf("error counting items: %v", err)
+	}
+	resp["items"] = items
+	resp["total_items"] = total
+	return nil
+}
+
+// feverMark handles mark=item|feed|group&as=...&id=..., then adds the ID
+// list the client needs to update its state.
+func (srv *Server) feverMark(r *http.Request, user database.User, resp map[string]any) error {
+	ctx := r.Context()
+	id, err := strconv.ParseInt(r.Form.Get("id"), 10, 64)
+	if err != nil {
+		return badFeverRequest(http.StatusBadRequest, "invalid id")
+	}
+	as := r.Form.Get("as")
+
+	switch r.Form.Get("mark") {
+	case "item":
+		postID, err := srv.DB.GetPostIDBySeq(ctx, id)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return badFeverRequest(http.StatusNotFound, "item does not exist")
+			}
+			return fmt.Errorf("error retrieving item: %v", err)
+		}
+		switch as {
+		case "read", "unread":
+			_, err = srv.DB.SetPostRead(ctx, database.SetPostReadParams{UserID: user.ID, PostID: postID, Read: as == "read"})
+			if err == nil {
+				err = srv.addUnreadIDs(r, user, resp)
+			}
+		case "saved", "unsaved":
+			_, err = srv.DB.SetPostStarred(ctx, database.SetPostStarredParams{UserID: user.ID, PostID: postID, Starred: as == "saved"})
+			if err == nil {
+				err = srv.addSavedIDs(r, user, resp)
+			}
+		default:
+			return badFeverRequest(http.StatusBadRequest, "invalid as")
+		}
+		if err != nil {
+			return fmt.Errorf("error marking item: %v", err)
+		}
+
+	case "feed", "group":
+		if as != "read" {
+			return badFeverRequest(http.StatusBadRequest, "invalid as")
+		}
+		params := database.MarkFeverPostsReadParams{UserID: user.ID, Before: time.Now()}
+		if s := r.Form.Get("before"); s != "" {
+			before, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				return badFeverRequest(http.StatusBadRequest, "invalid before")
+			}
+			params.Before = time.Unix(before, 0)
+		}
+		if r.Form.Get("mark") == "feed" {
+			params.FeedSeq = sql.NullInt64{Int64: id, Valid: true}
+		} else if id != 0 && id != feverGroupID {
+			// Group 0 is Fever's "all items"; any other group is unknown
+			return badFeverRequest(http.StatusNotFound, "group does not exist")
+		}
+		if err := srv.DB.MarkFeverPostsRead(ctx, params); err != nil {
+			return fmt.Errorf("error marking items read: %v", err)
+		}
+		if err := srv.addUnreadIDs(r, user, resp); err != nil {
+			return err
+		}
+
+	default:
+		return badFeverRequest(http.StatusBadRequest, "invalid mark")
+	}
+	return nil
+}
+
+func (srv *Server) addUnreadIDs(r *http.Request, user database.User, resp map[string]any) error {
+	ids, err := srv.DB.GetUnreadFeverItemIDs(r.Context(), user.ID)
+	if err != nil {
+		return err
+	}
+	resp["unread_item_ids"] = joinIDs(ids)
+	return nil
+}
+
+func (srv *Server) addSavedIDs(r *http.Request, user database.User, resp map[string]any) error {
+	ids, err := srv.DB.GetSavedFeverItemIDs(r.Context(), user.ID)
+	if err != nil {
+		return err
+	}
+	resp["saved_item_ids"] = joinIDs(ids)
+	return nil
+}
+
+func joinIDs(ids []int64) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.FormatInt(id, 10)
+	}
+	return strings.Join(parts, ",")
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JadedPigeon/Gator/internal/auth"
+	"github.com/JadedPigeon/Gator/internal/database"
+	"github.com/JadedPigeon/Gator/internal/dbtest"
+	"github.com/google/uuid"
+)
+
+func TestFeverAuth(t *testing.T) {
+	sqlDB, db := dbtest.Queries(t)
+	ctx := context.Background()
+	now := time.Now()
+	user, err := db.CreateUser(ctx, database.CreateUserParams{ID: uuid.New(), CreatedAt: now, UpdatedAt: now, Name: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := auth.FeverKey("alice", "secret")
+	err = db.SetFeverKey(ctx, database.SetFeverKeyParams{UserID: user.ID, KeyHash: auth.HashToken(key)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer((&Server{DB: db}).Handler())
+	defer srv.Close()
+
+	feverAuth := func(apiKey string) int {
+		t.Helper()
+		resp, err := http.PostForm(srv.URL+"/fever/?api", url.Values{"api_key": {apiKey}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var body struct {
+			Auth int `json:"auth"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		return body.Auth
+	}
+	if got := feverAuth(key); got != 1 {
+		t.Errorf("correct key: auth = %d, want 1", got)
+	}
+	// Some clients send the hex digest in upper case
+	if got := feverAuth(strings.ToUpper(key)); got != 1 {
+		t.Errorf("upper-case key: auth = %d, want 1", got)
+	}
+	if got := feverAuth(auth.FeverKey("alice", "wrong")); got != 0 {
+		t.Errorf("wrong key: auth = %d, want 0", got)
+	}
+	// Someone who can read the table must not be able to log in with it
+	var stored string
+	if err := sqlDB.QueryRow("SELECT key_hash FROM fever_keys WHERE user_id = $1", user.ID).Scan(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored == key {
+		t.Error("the Fever key is stored in plain text")
+	}
+	if got := feverAuth(stored); got != 0 {
+		t.Errorf("stored hash used as the key: auth = %d, want 0", got)
+	}
+}
+
+func TestFeverErrors(t *testing.T) {
+	sqlDB, db := dbtest.Queries(t)
+	ctx := context.Background()
+	now := time.Now()
+	user, err := db.CreateUser(ctx, database.CreateUserParams{ID: uuid.New(), CreatedAt: now, UpdatedAt: now, Name: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := auth.FeverKey("alice", "secret")
+	err = db.SetFeverKey(ctx, database.SetFeverKeyParams{UserID: user.ID, KeyHash: auth.HashToken(key)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var logs bytes.Buffer
+	srv := httptest.NewServer((&Server{DB: db, Log: slog.New(slog.NewTextHandler(&logs, nil))}).Handler())
+	defer srv.Close()
+
+	fever := func(query string, form url.Values) int {
+		t.Helper()
+		form.Set("api_key", key)
+		resp, err := http.PostForm(srv.URL+"/fever/?api&"+query, form)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	tests := []struct {
+		name  string
+		query string
+		form  url.Values
+		want  int
+	}{
+		{"items", "items", url.Values{}, http.StatusOK},
+		{"bad since_id", "items&since_id=x", url.Values{}, http.StatusBadRequest},
+		{"bad mark", "", url.Values{"mark": {"folder"}, "as": {"read"}, "id": {"1"}}, http.StatusBadRequest},
+		{"missing item", "", url.Values{"mark": {"item"}, "as": {"read"}, "id": {"999"}}, http.StatusNotFound},
+		{"missing group", "", url.Values{"mark": {"group"}, "as": {"read"}, "id": {"7"}}, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if got := fever(tt.query, tt.form); got != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, got, tt.want)
+		}
+	}
+	if logs.Len() > 0 {
+		t.Errorf("client errors were logged as server errors:\n%s", logs.String())
+	}
+
+	// A database failure is a server error, and is logged
+	if _, err := sqlDB.Exec("DROP TABLE post_states CASCADE"); err != nil {
+		t.Fatal(err)
+	}
+	if got := fever("items", url.Values{}); got != http.StatusInternalServerError {
+		t.Errorf("items without post_states: status %d, want 500", got)
+	}
+	if !strings.Contains(logs.String(), "error retrieving items") {
+		t.Errorf("database error not logged, got:\n%s", logs.String())
+	}
+}
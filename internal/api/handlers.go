@@ -0,0 +1,316 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/JadedPigeon/Gator/internal/database"
+	"github.com/JadedPigeon/Gator/internal/export"
+	"github.com/google/uuid"
+)
+
+type userJSON struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type feedJSON struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	URL       string    `json:"url"`
+	AddedBy   string    `json:"added_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type followJSON struct {
+	ID        uuid.UUID `json:"id"`
+	FeedID    uuid.UUID `json:"feed_id"`
+	FeedName  string    `json:"feed_name"`
+	FeedURL   string    `json:"feed_url,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type thumbnailJSON struct {
+	URL    string `json:"url"`
+	Width  *int32 `json:"width"`
+	Height *int32 `json:"height"`
+}
+
+type postJSON struct {
+	ID          uuid.UUID      `json:"id"`
+	FeedID      uuid.UUID      `json:"feed_id"`
+	FeedName    string         `json:"feed_name"`
+	Title       string         `json:"title"`
+	URL         string         `json:"url"`
+	Description *string        `json:"description"`
+	PublishedAt *time.Time     `json:"published_at"`
+	Author      *string        `json:"author"`
+	Tags        *string        `json:"tags"`
+	CommentsURL *string        `json:"comments_url"`
+	Thumbnail   *thumbnailJSON `json:"thumbnail"`
+	Read        bool           `json:"read"`
+	Starred     bool           `json:"starred"`
+}
+
+func (srv *Server) handleUsers(w http.ResponseWriter, r *http.Request, _ database.User) {
+	users, err := srv.DB.GetAllUsers(r.Context())
+	if err != nil {
+		srv.internalError(w, r, err)
+		return
+	}
+	out := make([]userJSON, 0, len(users))
+	for _, u := range users {
+		out = append(out, userJSON{ID: u.ID, Name: u.Name, CreatedAt: u.CreatedAt})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (srv *Server) handleFeeds(w http.ResponseWriter, r *http.Request, _ database.User) {
+	feeds, err := srv.DB.GetAllFeeds(r.Context())
+	if err != nil {
+		srv.internalError(w, r, err)
+		return
+	}
+	out := make([]feedJSON, 0, len(feeds))
+	for _, f := range feeds {
+		out = append(out, feedJSON{ID: f.ID, Name: f.Name, URL: f.Url, AddedBy: f.UserName, CreatedAt: f.CreatedAt})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (srv *Server) handleFollows(w http.ResponseWriter, r *http.Request, user database.User) {
+	follows, err := srv.DB.GetFeedFollowsForUser(r.Context(), user.ID)
+	if err != nil {
+		srv.internalError(w, r, err)
+		return
+	}
+	out := make([]followJSON, 0, len(follows))
+	for _, f := range follows {
+		out = append(out, followJSON{ID: f.ID, FeedID: f.FeedID, FeedName: f.FeedName, FeedURL: f.FeedUrl, CreatedAt: f.CreatedAt})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (srv *Server) handleFollow(w http.ResponseWriter, r *http.Request, user database.User) {
+	var body struct {
+		FeedURL string `json:"feed_url"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.FeedURL == "" {
+		writeError(w, http.StatusBadRequest, `request body must be JSON like {"feed_url": "..."}`)
+		return
+	}
+	feed, err := srv.DB.GetFeedByUrl(r.Context(), body.FeedURL)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			writeError(w, http.StatusNotFound, "feed does not exist")
+			return
+		}
+		srv.internalError(w, r, err)
+		return
+	}
+	follow, err := srv.DB.CreateFeedFollow(r.Context(), database.CreateFeedFollowParams{
+		FeedID: feed.ID,
+		UserID: user.ID,
+	})
+	if err != nil {
+		if pqCode(err) == uniqueViolation {
+			writeError(w, http.StatusConflict, "already following this feed")
+			return
+		}
+		srv.internalError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, followJSON{
+		ID:        follow.ID,
+		FeedID:    follow.FeedID,
+		FeedName:  follow.FeedName,
+		FeedURL:   feed.Url,
+		CreatedAt: follow.CreatedAt,
+	})
+}
+
+func (srv *Server) handleUnfollow(w http.ResponseWriter, r *http.Request, user database.User) {
+	feedID, err := uuid.Parse(r.PathValue("feedID"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid feed ID")
+		return
+	}
+	deleted, err := srv.DB.DeleteFeedFollow(r.Context(), database.DeleteFeedFollowParams{
+		FeedID: feedID,
+		UserID: user.ID,
+	})
+	if err != nil {
+		srv.internalError(w, r, err)
+		return
+	}
+	if deleted == 0 {
+		writeError(w, http.StatusNotFound, "you are not following this feed")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlePosts lists the user's posts, newest first. It takes limit, offset,
+// tag, author, feed_id and q (search) query parameters.
+func (srv *Server) handlePosts(w http.ResponseWriter, r *http.Request, user database.User) {
+	limit, offset, err := pageParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	query := r.URL.Query()
+	var feedID uuid.NullUUID
+	if s := query.Get("feed_id"); s != "" {
+		id, err := uuid.Parse(s)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid feed_id")
+			return
+		}
+		feedID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+	posts, err := srv.DB.GetPostsForUser(r.Context(), database.GetPostsForUserParams{
+		UserID:    user.ID,
+		Tag:       optionalString(query.Get("tag")),
+		Author:    optionalString(query.Get("author")),
+		FeedID:    feedID,
+		Search:    optionalString(query.Get("q")),
+		RowLimit:  int32(limit),
+		RowOffset: int32(offset),
+	})
+	if err != nil {
+		srv.internalError(w, r, err)
+		return
+	}
+	out := make([]postJSON, 0, len(posts))
+	for _, p := range posts {
+		out = append(out, postFromRow(p))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (srv *Server) handleMarkRead(read bool) userHandler {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		postID, err := uuid.Parse(r.PathValue("postID"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid post ID")
+			return
+		}
+		_, err = srv.DB.SetPostRead(r.Context(), database.SetPostReadParams{
+			UserID: user.ID,
+			PostID: postID,
+			Read:   read,
+		})
+		if err != nil {
+			if pqCode(err) == foreignKeyViolation {
+				writeError(w, http.StatusNotFound, "post does not exist")
+				return
+			}
+			srv.internalError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// handleFeed serves the user's timeline as RSS or Atom. It takes format,
+// tag and limit query parameters.
+func (srv *Server) handleFeed(w http.ResponseWriter, r *http.Request, user database.User) {
+	query := r.URL.Query()
+	format := export.FormatRSS
+	if s := query.Get("format"); s != "" {
+		f, err := export.ParseFormat(s)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		format = f
+	}
+	limit, _, err := pageParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	// Keep the token out of the document, which readers may cache or share
+	selfQuery := r.URL.Query()
+	selfQuery.Del("token")
+	self := url.URL{Scheme: scheme, Host: r.Host, Path: r.URL.Path, RawQuery: selfQuery.Encode()}
+
+	var buf bytes.Buffer
+	err = export.Write(r.Context(), &buf, srv.DB, user, export.Options{
+		Format:  format,
+		Tag:     query.Get("tag"),
+		Limit:   limit,
+		Link:    scheme + "://" + r.Host + "/",
+		SelfURL: self.String(),
+	})
+	if err != nil {
+		srv.internalError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Write(buf.Bytes())
+}
+
+func postFromRow(p database.GetPostsForUserRow) postJSON {
+	out := postJSON{
+		ID:          p.ID,
+		FeedID:      p.FeedID,
+		FeedName:    p.FeedName,
+		Title:       p.Title,
+		URL:         p.Url,
+		Description: nullString(p.Description),
+		Author:      nullString(p.Author),
+		Tags:        joinTags(p.Tags),
+		CommentsURL: nullString(p.CommentsUrl),
+		Read:        p.Read,
+		Starred:     p.Starred,
+	}
+	if p.PublishedAt.Valid {
+		out.PublishedAt = &p.PublishedAt.Time
+	}
+	if p.ThumbnailUrl.Valid {
+		out.Thumbnail = &thumbnailJSON{
+			URL:    p.ThumbnailUrl.String,
+			Width:  nullInt32(p.ThumbnailWidth),
+			Height: nullInt32(p.ThumbnailHeight),
+		}
+	}
+	return out
+}
+
+func optionalString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// joinTags lists a post's categories as one string, or nil if it has none.
+func joinTags(tags []string) *string {
+	if len(tags) == 0 {
+		return nil
+	}
+	s := strings.Join(tags, ", ")
+	return &s
+}
+
+func nullString(s sql.NullString) *string {
+	if !s.Valid {
+		return nil
+	}
+	return &s.String
+}
+
+func nullInt32(n sql.NullInt32) *int32 {
+	if !n.Valid {
+		return nil
+	}
+	return &n.Int32
+}
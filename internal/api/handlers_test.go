@@ -0,0 +1,139 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JadedPigeon/Gator/internal/auth"
+	"github.com/JadedPigeon/Gator/internal/database"
+	"github.com/JadedPigeon/Gator/internal/dbtest"
+	"github.com/google/uuid"
+)
+
+// testServer starts the API against a fresh database with one user, who
+// owns token and one feed.
+func testServer(t *testing.T) (srv *httptest.Server, token string, feed database.Feed) {
+	t.Helper()
+	_, db := dbtest.Queries(t)
+	ctx := context.Background()
+	now := time.Now()
+	user, err := db.CreateUser(ctx, database.CreateUserParams{ID: uuid.New(), CreatedAt: now, UpdatedAt: now, Name: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err = auth.NewToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.CreateAPIToken(ctx, database.CreateAPITokenParams{UserID: user.ID, Name: "test", TokenHash: auth.HashToken(token)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	feed, err = db.CreateFeed(ctx, database.CreateFeedParams{Name: "Blog", Url: "https://example.com/feed.xml", UserID: user.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv = httptest.NewServer((&Server{DB: db}).Handler())
+	t.Cleanup(srv.Close)
+	return srv, token, feed
+}
+
+// call sends a request and returns the status and the decoded error message,
+// if any.
+func call(t *testing.T, method, url, token, body string) (int, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var e errorResponse
+	json.NewDecoder(resp.Body).Decode(&e)
+	return resp.StatusCode, e.Error
+}
+
+func TestMissingToken(t *testing.T) {
+	// Rejected before the database is touched, so this needs no database
+	srv := httptest.NewServer((&Server{}).Handler())
+	defer srv.Close()
+	for _, path := range []string{"/api/users", "/api/posts", "/api/feed"} {
+		req, _ := http.NewRequest("GET", srv.URL+path, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("%s without a token: status %d, want 401", path, resp.StatusCode)
+		}
+		if resp.Header.Get("WWW-Authenticate") == "" {
+			t.Errorf("%s: no WWW-Authenticate header on a 401", path)
+		}
+	}
+}
+
+func TestStatusCodes(t *testing.T) {
+	srv, token, feed := testServer(t)
+	follow := `{"feed_url": "` + feed.Url + `"}`
+
+	tests := []struct {
+		name         string
+		method, path string
+		token, body  string
+		want         int
+	}{
+		{"unknown token", "GET", "/api/posts", "not-a-token", "", http.StatusUnauthorized},
+		{"token in query outside /api/feed", "GET", "/api/posts?token=" + token, "", "", http.StatusUnauthorized},
+		{"posts", "GET", "/api/posts", token, "", http.StatusOK},
+		{"limit not a number", "GET", "/api/posts?limit=ten", token, "", http.StatusBadRequest},
+		{"limit too large", "GET", "/api/posts?limit=1000", token, "", http.StatusBadRequest},
+		{"limit zero", "GET", "/api/posts?limit=0", token, "", http.StatusBadRequest},
+		{"negative offset", "GET", "/api/posts?offset=-1", token, "", http.StatusBadRequest},
+		{"bad feed_id", "GET", "/api/posts?feed_id=nope", token, "", http.StatusBadRequest},
+		{"bad feed format", "GET", "/api/feed?format=yaml", token, "", http.StatusBadRequest},
+		{"follow without a body", "POST", "/api/follows", token, "", http.StatusBadRequest},
+		{"follow a missing feed", "POST", "/api/follows", token, `{"feed_url": "https://nowhere.example.com/"}`, http.StatusNotFound},
+		{"follow", "POST", "/api/follows", token, follow, http.StatusCreated},
+		{"follow twice", "POST", "/api/follows", token, follow, http.StatusConflict},
+		{"unfollow a bad ID", "DELETE", "/api/follows/nope", token, "", http.StatusBadRequest},
+		{"mark a missing post read", "PUT", "/api/posts/" + uuid.NewString() + "/read", token, "", http.StatusNotFound},
+		{"mark a bad post ID read", "PUT", "/api/posts/nope/read", token, "", http.StatusBadRequest},
+		{"unfollow", "DELETE", "/api/follows/" + feed.ID.String(), token, "", http.StatusNoContent},
+		{"unfollow twice", "DELETE", "/api/follows/" + feed.ID.String(), token, "", http.StatusNotFound},
+		{"unfollow a missing feed", "DELETE", "/api/follows/" + uuid.NewString(), token, "", http.StatusNotFound},
+	}
+	// The cases share one database and run in order
+	for _, tt := range tests {
+		status, msg := call(t, tt.method, srv.URL+tt.path, tt.token, tt.body)
+		if status != tt.want {
+			t.Errorf("%s: status %d (%q), want %d", tt.name, status, msg, tt.want)
+		}
+		if status >= 400 && msg == "" {
+			t.Errorf("%s: error response has no message", tt.name)
+		}
+	}
+}
+
+func TestFeedAcceptsQueryToken(t *testing.T) {
+	srv, token, _ := testServer(t)
+	resp, err := http.Get(srv.URL + "/api/feed?token=" + token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d, want 200", resp.StatusCode)
+	}
+}
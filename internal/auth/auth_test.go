@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+)
+
+func TestCheckPassword(t *testing.T) {
+	hash, err := HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=65536,t=3,p=4$") {
+		t.Errorf("hash = %q, want argon2id with the default parameters", hash)
+	}
+	if ok, err := CheckPassword(hash, "correct horse"); err != nil || !ok {
+		t.Errorf("right password: got %v, %v", ok, err)
+	}
+	if ok, err := CheckPassword(hash, "wrong"); err != nil || ok {
+		t.Errorf("wrong password: got %v, %v", ok, err)
+	}
+
+	other, err := HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == hash {
+		t.Error("two hashes of the same password are equal; the salt isn't random")
+	}
+}
+
+func TestCheckPasswordOtherParameters(t *testing.T) {
+	// A hash records its parameters, so raising the defaults doesn't break
+	// passwords hashed before
+	salt := []byte("saltsaltsaltsalt")
+	key := argon2.IDKey([]byte("correct horse"), salt, 1, 8, 1, 16)
+	enc := base64.RawStdEncoding
+	hash := "$argon2id$v=19$m=8,t=1,p=1$" + enc.EncodeToString(salt) + "$" + enc.EncodeToString(key)
+	if ok, err := CheckPassword(hash, "correct horse"); err != nil || !ok {
+		t.Errorf("got %v, %v, want a match", ok, err)
+	}
+}
+
+func TestCheckPasswordMalformed(t *testing.T) {
+	for _, hash := range []string{
+		"",
+		"pbkdf2-sha256$600000$c2FsdA$a2V5",
+		"$argon2i$v=19$m=8,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=16$m=8,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=0,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=8,t=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=8,t=1,p=1$not base64!$a2V5",
+		"$argon2id$v=19$m=8,t=1,p=1$c2FsdA$",
+	} {
+		if _, err := CheckPassword(hash, "x"); err != ErrMalformedHash {
+			t.Errorf("CheckPassword(%q): got %v, want ErrMalformedHash", hash, err)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/JadedPigeon/Gator/internal/database"
+	"github.com/JadedPigeon/Gator/internal/hook"
+	"github.com/JadedPigeon/Gator/internal/webhook"
+	"github.com/google/uuid"
+)
+
+// pendingAlert is a post that matched one of a user's alert rules.
+type pendingAlert struct {
+	UserID uuid.UUID
+	PostID uuid.UUID
+}
+
+// runAlerts runs the alert command for each alert, one at a time. Without
+// a command configured the alert is just printed.
+func runAlerts(ctx context.Context, s *State, alerts []pendingAlert) {
+	for _, pa := range alerts {
+		if ctx.Err() != nil {
+			return
+		}
+		row, err := s.DB.GetAlertPost(ctx, database.GetAlertPostParams{
+			UserID: pa.UserID,
+			PostID: pa.PostID,
+		})
+		if err != nil {
+			s.Log.Error("Loading alert failed", "post_id", pa.PostID, "error", err)
+			continue
+		}
+		if s.Cfg.AlertCommand == "" {
+			s.Log.Info("Alert", "user", row.UserName, "feed_url", row.FeedUrl, "post_id", row.ID,
+				"post_title", row.Title, "post_url", row.Url)
+			continue
+		}
+		a := hook.Alert{
+			User: row.UserName,
+			Payload: webhook.Payload{
+				Event: hook.EventAlert,
+				Feed:  webhook.Feed{Name: row.FeedName, URL: row.FeedUrl},
+				Post: webhook.Post{
+					ID:          row.ID.String(),
+					Title:       row.Title,
+					URL:         row.Url,
+					Description: row.Description.String,
+					Author:      row.Author.String,
+				},
+			},
+		}
+		if row.PublishedAt.Valid {
+			a.Post.PublishedAt = &row.PublishedAt.Time
+		}
+		if err := hook.Run(ctx, s.Log, s.Cfg.AlertCommand, a); err != nil {
+			s.Log.Error("Alert command failed", "user", row.UserName, "post_id", row.ID, "error", err)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/JadedPigeon/Gator/internal/auth"
+	"github.com/JadedPigeon/Gator/internal/database"
+	"github.com/google/uuid"
+)
+
+// Environment variables that supply passwords instead of a prompt, for
+// scripts and tests.
+const (
+	passwordEnv    = "GATOR_PASSWORD"
+	newPasswordEnv = "GATOR_NEW_PASSWORD"
+)
+
+// readPassword takes a password from envVar if it is set, and otherwise
+// prompts for it on the terminal without echoing.
+func readPassword(envVar, prompt string) (string, error) {
+	if pw, ok := os.LookupEnv(envVar); ok {
+		return pw, nil
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	restore := disableEcho()
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	restore()
+	fmt.Fprintln(os.Stderr)
+	if err != nil && !(err == io.EOF && line != "") {
+		return "", fmt.Errorf("error reading password: %v", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// disableEcho turns off terminal echo with stty and returns a function that
+// turns it back on. Where that isn't possible the password is echoed.
+func disableEcho() func() {
+	if info, err := os.Stdin.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return func() {}
+	}
+	stty := func(arg string) error {
+		cmd := exec.Command("stty", arg)
+		cmd.Stdin = os.Stdin
+		return cmd.Run()
+	}
+	if err := stty("-echo"); err != nil {
+		return func() {}
+	}
+	return func() { stty("echo") }
+}
+
+// readNewPassword asks for a new password, twice when prompting, and
+// returns it along with its hash.
+func readNewPassword(envVar string) (string, string, error) {
+	pw, err := readPassword(envVar, "New password: ")
+	if err != nil {
+		return "", "", err
+	}
+	if pw == "" {
+		return "", "", errors.New("password must not be empty")
+	}
+	if _, ok := os.LookupEnv(envVar); !ok {
+		again, err := readPassword(envVar, "Repeat password: ")
+		if err != nil {
+			return "", "", err
+		}
+		if again != pw {
+			return "", "", errors.New("passwords do not match")
+		}
+	}
+	hash, err := auth.HashPassword(pw)
+	if err != nil {
+		return "", "", fmt.Errorf("error hashing password: %v", err)
+	}
+	return pw, hash, nil
+}
+
+// checkUserPassword prompts for the user's password, verifies it and
+// returns it.
+func checkUserPassword(user database.User) (string, error) {
+	pw, err := readPassword(passwordEnv, fmt.Sprintf("Password for %s: ", user.Name))
+	if err != nil {
+		return "", err
+	}
+	ok, err := auth.CheckPassword(user.PasswordHash.String, pw)
+	if err != nil {
+		return "", fmt.Errorf("error checking password: %v", err)
+	}
+	if !ok {
+		return "", errors.New("incorrect password")
+	}
+	return pw, nil
+}
+
+func HandlerPasswd(ctx context.Context, s *State, cmd Command, user database.User) error {
+	if user.PasswordHash.Valid {
+		if _, err := checkUserPassword(user); err != nil {
+			return err
+		}
+	}
+	pw, hash, err := readNewPassword(newPasswordEnv)
+	if err != nil {
+		return err
+	}
+	if user.PasswordHash.Valid {
+		err = s.DB.SetUserPassword(ctx, database.SetUserPasswordParams{
+			ID:           user.ID,
+			PasswordHash: sql.NullString{String: hash, Valid: true},
+		})
+	} else {
+		// A user from before passwords existed is claimed by being logged
+		// in as them; the old password check above had nothing to verify
+		_, err = s.DB.ClaimUser(ctx, database.ClaimUserParams{
+			Name:         user.Name,
+			PasswordHash: sql.NullString{String: hash, Valid: true},
+		})
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("a password for %s was set in the meantime; log in with it first", user.Name)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("error setting password: %v", err)
+	}
+	// The Fever key is derived from the password, so it changes with it
+	err = s.DB.UpdateFeverKey(ctx, database.UpdateFeverKeyParams{
+		UserID:  user.ID,
+		KeyHash: auth.HashToken(auth.FeverKey(user.Name, pw)),
+	})
+	if err != nil {
+		return fmt.Errorf("error updating Fever key: %v", err)
+	}
+	fmt.Printf("Password for %s updated\n", user.Name)
+	return nil
+}
+
+func HandlerTokenCreate(ctx context.Context, s *State, cmd Command, user database.User) error {
+	token, err := auth.NewToken()
+	if err != nil {
+		return fmt.Errorf("error generating token: %v", err)
+	}
+	created, err := s.DB.CreateAPIToken(ctx, database.CreateAPITokenParams{
+		UserID:    user.ID,
+		Name:      cmd.Args[0],
+		TokenHash: auth.HashToken(token),
+	})
+	if err != nil {
+		return fmt.Errorf("error creating token: %v", err)
+	}
+	fmt.Printf("Token %s (%s) created. Copy it now, it won't be shown again:\n", created.Name, created.ID)
+	fmt.Println(token)
+	return nil
+}
+
+func HandlerTokens(ctx context.Context, s *State, cmd Command, user database.User) error {
+	tokens, err := s.DB.GetAPITokensForUser(ctx, user.ID)
+	if err != nil {
+		return fmt.Errorf("error retrieving tokens: %v", err)
+	}
+	records := Records{
+		Columns: []string{"id", "name", "created_at", "last_used_at"},
+		Empty:   "No API tokens found.",
+	}
+	for _, t := range tokens {
+		records.Add(t.ID, t.Name, t.CreatedAt, t.LastUsedAt)
+	}
+	return s.Emit(records)
+}
+
+func HandlerTokenRevoke(ctx context.Context, s *State, cmd Command, user database.User) error {
+	tokenID, err := uuid.Parse(cmd.Args[0])
+	if err != nil {
+		return fmt.Errorf("invalid token ID %q", cmd.Args[0])
+	}
+	deleted, err := s.DB.DeleteAPIToken(ctx, database.DeleteAPITokenParams{
+		ID:     tokenID,
+		UserID: user.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("error revoking token: %v", err)
+	}
+	if deleted == 0 {
+		return fmt.Errorf("token %s does not exist", tokenID)
+	}
+	fmt.Printf("Token %s revoked\n", tokenID)
+	return nil
+}
+
+func HandlerFeverEnable(ctx context.Context, s *State, cmd Command, user database.User) error {
+	if !user.PasswordHash.Valid {
+		return errors.New("set a password with 'gator passwd' first")
+	}
+	pw, err := checkUserPassword(user)
+	if err != nil {
+		return err
+	}
+	err = s.DB.SetFeverKey(ctx, database.SetFeverKeyParams{
+		UserID:  user.ID,
+		KeyHash: auth.HashToken(auth.FeverKey(user.Name, pw)),
+	})
+	if err != nil {
+		return fmt.Errorf("error enabling Fever: %v", err)
+	}
+	fmt.Printf("Fever API enabled for %s. Log in from your reader with your user name and password.\n", user.Name)
+	return nil
+}
+
+func HandlerFeverDisable(ctx context.Context, s *State, cmd Command, user database.User) error {
+	n, err := s.DB.DeleteFeverKey(ctx, user.ID)
+	if err != nil {
+		return fmt.Errorf("error disabling Fever: %v", err)
+	}
+	if n == 0 {
+		fmt.Printf("Fever API was not enabled for %s\n", user.Name)
+		return nil
+	}
+	fmt.Printf("Fever API disabled for %s\n", user.Name)
+	return nil
+}
@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JadedPigeon/Gator/internal/config"
+	"github.com/JadedPigeon/Gator/internal/database"
+	"github.com/JadedPigeon/Gator/internal/dbtest"
+	"github.com/google/uuid"
+)
+
+// authState returns a State on a fresh database with the config written to
+// a temporary file, and a user named "old" who has no password.
+func authState(t *testing.T) *State {
+	t.Helper()
+	t.Setenv("GATOR_CONFIG_PATH", filepath.Join(t.TempDir(), "config.json"))
+	_, db := dbtest.Queries(t)
+	now := time.Now()
+	_, err := db.CreateUser(context.Background(), database.CreateUserParams{ID: uuid.New(), CreatedAt: now, UpdatedAt: now, Name: "old"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &State{Cfg: &config.Config{}, DB: db}
+}
+
+func TestLoginRefusesUserWithoutPassword(t *testing.T) {
+	s := authState(t)
+	t.Setenv(passwordEnv, "")
+	err := HandlerLogin(context.Background(), s, Command{Name: "login", Args: []string{"old"}})
+	if err == nil || !strings.Contains(err.Error(), "gator passwd") {
+		t.Fatalf("got %v, want an error pointing at passwd", err)
+	}
+	if s.Cfg.CurrentUser != "" {
+		t.Errorf("current user set to %q", s.Cfg.CurrentUser)
+	}
+}
+
+func TestRegisterRefusesUserWithoutPassword(t *testing.T) {
+	// Anyone could run register, so it must not hand them an old account
+	s := authState(t)
+	ctx := context.Background()
+	t.Setenv(passwordEnv, "correct horse")
+	err := HandlerRegister(ctx, s, Command{Name: "register", Args: []string{"old"}})
+	if err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("got %v, want already exists", err)
+	}
+	user, err := s.DB.GetUser(ctx, "old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.PasswordHash.Valid {
+		t.Error("register set a password on an existing user")
+	}
+	if s.Cfg.CurrentUser != "" {
+		t.Errorf("current user set to %q", s.Cfg.CurrentUser)
+	}
+}
+
+func TestPasswdClaimsUserWithoutPassword(t *testing.T) {
+	s := authState(t)
+	ctx := context.Background()
+	passwd := MiddlewareLoggedIn(HandlerPasswd)
+	t.Setenv(newPasswordEnv, "correct horse")
+
+	if err := passwd(ctx, s, Command{Name: "passwd"}); err == nil {
+		t.Fatal("passwd succeeded without being logged in")
+	}
+
+	// Still logged in from before passwords existed
+	s.Cfg.CurrentUser = "old"
+	if err := passwd(ctx, s, Command{Name: "passwd"}); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(passwordEnv, "correct horse")
+	if err := HandlerLogin(ctx, s, Command{Name: "login", Args: []string{"old"}}); err != nil {
+		t.Errorf("login with the new password: %v", err)
+	}
+	t.Setenv(passwordEnv, "wrong")
+	if err := HandlerLogin(ctx, s, Command{Name: "login", Args: []string{"old"}}); err == nil {
+		t.Error("login with the wrong password succeeded")
+	}
+}
+
+func TestClaimUserOnlyOnce(t *testing.T) {
+	s := authState(t)
+	ctx := context.Background()
+	claim := func(hash string) error {
+		_, err := s.DB.ClaimUser(ctx, database.ClaimUserParams{
+			Name:         "old",
+			PasswordHash: sql.NullString{String: hash, Valid: true},
+		})
+		return err
+	}
+	if err := claim("first"); err != nil {
+		t.Fatal(err)
+	}
+	if err := claim("second"); err != sql.ErrNoRows {
+		t.Errorf("second claim: got %v, want sql.ErrNoRows", err)
+	}
+	user, err := s.DB.GetUser(ctx, "old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.PasswordHash.String != "first" {
+		t.Errorf("password hash = %q, want the first claim's", user.PasswordHash.String)
+	}
+}
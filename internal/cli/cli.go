@@ -4,73 +4,267 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
 	"os/signal"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/JadedPigeon/Gator/internal/config"
+	"github.com/JadedPigeon/Gator/internal/daemon"
 	"github.com/JadedPigeon/Gator/internal/database"
+	"github.com/JadedPigeon/Gator/internal/filter"
 	"github.com/JadedPigeon/Gator/internal/rss"
 	"github.com/google/uuid"
 )
 
+const (
+	defaultCommandTimeout = 30 * time.Second
+	defaultFetchTimeout   = 30 * time.Second
+)
+
 type State struct {
 	Cfg *config.Config
 	DB  *database.Queries
+	// Conn is the connection behind DB, for work that needs a transaction.
+	Conn *sql.DB
+	// Format selects how list commands write their records to Out.
+	Format OutputFormat
+	Out    io.Writer
+	// Fetcher is shared by everything that fetches feeds; see feedFetcher.
+	Fetcher *rss.Fetcher
+	// Log receives progress and errors from background work such as agg,
+	// as opposed to a command's output, which goes to Out.
+	Log *slog.Logger
+	// LogLevel and LogFormat are what Log was built with. LogFormat is
+	// empty if neither --log-format nor the config chose one.
+	LogLevel  slog.Level
+	LogFormat string
+	// Metrics is set when agg serves metrics; nil records nothing.
+	Metrics *aggMetrics
+}
+
+// withTx returns queries that run in tx.
+func (s *State) withTx(tx *sql.Tx) *database.Queries {
+	return database.New(s.Metrics.db(tx))
+}
+
+// feedFetcher returns the shared Fetcher, building it from the config on
+// first use.
+func (s *State) feedFetcher() (*rss.Fetcher, error) {
+	if s.Fetcher != nil {
+		return s.Fetcher, nil
+	}
+	fetcher, err := rss.NewFetcher(rss.FetcherConfig{
+		UserAgent:    s.Cfg.UserAgent,
+		Timeout:      s.Cfg.FetchTimeout.Or(defaultFetchTimeout),
+		MaxRedirects: s.Cfg.MaxRedirects,
+		ProxyURL:     s.Cfg.ProxyURL,
+		CABundle:     s.Cfg.CABundle,
+		HostHeaders:  s.Cfg.HostHeaders,
+
+		MaxBodySize:          s.Cfg.MaxFeedBytes,
+		MaxDecodedSize:       s.Cfg.MaxFeedDecodedBytes,
+		BlockPrivateNetworks: s.Cfg.BlockPrivateNetworks,
+		Logger:               s.Log,
+		OnFetch:              s.Metrics.fetched,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error configuring feed fetcher: %v", err)
+	}
+	s.Fetcher = fetcher
+	return fetcher, nil
 }
 
 type Command struct {
 	Name string
 	Args []string
+	// Flags holds the command's parsed flags; see CommandInfo.SetFlags.
+	Flags *flag.FlagSet
+}
+
+// CommandInfo describes a command for argument checking, help and shell
+// completion.
+type CommandInfo struct {
+	// Usage is the argument synopsis shown after the command name.
+	Usage       string
+	Description string
+	MinArgs     int
+	// MaxArgs of -1 means the command takes any number of arguments.
+	MaxArgs  int
+	SetFlags func(fs *flag.FlagSet)
+	// Standalone commands run without a config file or database.
+	Standalone bool
+	// LongRunning commands run until interrupted instead of timing out.
+	LongRunning bool
+	// Document commands write a file format to stdout, which must not get
+	// a status line appended.
+	Document bool
 }
 
 type Commands struct {
-	Handlers map[string]func(*State, Command) error
+	Handlers map[string]func(context.Context, *State, Command) error
+	Info     map[string]CommandInfo
+	// Global holds the flags accepted before the command name, for help and
+	// completion output.
+	Global *flag.FlagSet
 }
 
-func (c *Commands) Run(s *State, cmd Command) error {
+// Run dispatches cmd to its handler. Commands that aren't long-running are
+// cancelled once the configured command timeout passes.
+func (c *Commands) Run(ctx context.Context, s *State, cmd Command) error {
 	handler, ok := c.Handlers[cmd.Name]
 	if !ok {
-		return fmt.Errorf("unknown command: %s", cmd.Name)
+		return fmt.Errorf("unknown command: %s (run 'gator help' to list commands)", cmd.Name)
 	}
-	return handler(s, cmd)
+	info := c.Info[cmd.Name]
+	fs := info.flagSet(cmd.Name)
+	args, err := parseArgs(fs, cmd.Args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return c.printCommandHelp(s.stdout(), cmd.Name)
+		}
+		return usageError(cmd.Name, info, err.Error())
+	}
+	if len(args) < info.MinArgs || (info.MaxArgs >= 0 && len(args) > info.MaxArgs) {
+		return usageError(cmd.Name, info, argCountProblem(info, len(args)))
+	}
+	cmd.Args = args
+	cmd.Flags = fs
+	if !info.LongRunning && s.Cfg != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Cfg.CommandTimeout.Or(defaultCommandTimeout))
+		defer cancel()
+	}
+	return handler(ctx, s, cmd)
 }
 
-func (c *Commands) Register(name string, f func(*State, Command) error) {
+func (c *Commands) Register(name string, f func(context.Context, *State, Command) error, info CommandInfo) {
 	c.Handlers[name] = f
+	c.Info[name] = info
+}
+
+// FlagString returns the value of a string flag, or "" if the command has no
+// such flag.
+func (c Command) FlagString(name string) string {
+	if c.Flags == nil {
+		return ""
+	}
+	f := c.Flags.Lookup(name)
+	if f == nil {
+		return ""
+	}
+	return f.Value.String()
+}
+
+func (c Command) FlagInt(name string) int {
+	n, _ := strconv.Atoi(c.FlagString(name))
+	return n
+}
+
+func (c Command) FlagBool(name string) bool {
+	b, _ := strconv.ParseBool(c.FlagString(name))
+	return b
+}
+
+func (info CommandInfo) flagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	if info.SetFlags != nil {
+		info.SetFlags(fs)
+	}
+	return fs
+}
+
+// parseArgs parses flags wherever they appear among the positional
+// arguments, so "filter-add title substring ad hide --feed URL" works as
+// well as putting the flags first. Everything after "--" is positional.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for len(args) > 0 {
+		if args[0] == "--" {
+			return append(positional, args[1:]...), nil
+		}
+		if len(args[0]) < 2 || args[0][0] != '-' {
+			positional = append(positional, args[0])
+			args = args[1:]
+			continue
+		}
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		rest := fs.Args()
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		args = rest
+	}
+	return positional, nil
+}
+
+func argCountProblem(info CommandInfo, got int) string {
+	switch {
+	case info.MaxArgs == 0:
+		return fmt.Sprintf("takes no arguments, got %d", got)
+	case info.MinArgs == info.MaxArgs:
+		return fmt.Sprintf("expected %d %s, got %d", info.MinArgs, plural(info.MinArgs, "argument"), got)
+	case info.MaxArgs < 0:
+		return fmt.Sprintf("expected at least %d %s, got %d", info.MinArgs, plural(info.MinArgs, "argument"), got)
+	default:
+		return fmt.Sprintf("expected %d to %d arguments, got %d", info.MinArgs, info.MaxArgs, got)
+	}
+}
+
+func usageError(name string, info CommandInfo, problem string) error {
+	return fmt.Errorf("%s\nusage: %s", problem, synopsis(name, info))
+}
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return word
+	}
+	return word + "s"
 }
 
-func MiddlewareLoggedIn(handler func(s *State, cmd Command, user database.User) error) func(*State, Command) error {
-	return func(s *State, cmd Command) error {
+func MiddlewareLoggedIn(handler func(ctx context.Context, s *State, cmd Command, user database.User) error) func(context.Context, *State, Command) error {
+	return func(ctx context.Context, s *State, cmd Command) error {
 		if s.Cfg.CurrentUser == "" {
 			return errors.New("you must be logged in to perform this action")
 		}
-		user, err := s.DB.GetUser(context.Background(), s.Cfg.CurrentUser)
+		user, err := s.DB.GetUser(ctx, s.Cfg.CurrentUser)
 		if err != nil {
 			if err == sql.ErrNoRows {
 				return fmt.Errorf("current user %s does not exist", s.Cfg.CurrentUser)
 			}
 			return fmt.Errorf("error checking current user: %v", err)
 		}
-		return handler(s, cmd, user)
+		return handler(ctx, s, cmd, user)
 	}
 }
 
-func HandlerLogin(s *State, cmd Command) error {
-	if cmd.Name == "" || len(cmd.Args) == 0 {
-		return errors.New("login command requires a username")
-	}
+func HandlerLogin(ctx context.Context, s *State, cmd Command) error {
 	username := cmd.Args[0]
-	_, err := s.DB.GetUser(context.Background(), username)
+	user, err := s.DB.GetUser(ctx, username)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return fmt.Errorf("user %s does not exist", username)
 		}
 		return fmt.Errorf("error checking user: %v", err)
 	}
+	// Users created before passwords existed can't prove who they are, so
+	// only whoever is still logged in as them may set the first password
+	if !user.PasswordHash.Valid {
+		return fmt.Errorf("user %s has no password; if you are still logged in as %s, set one with \"gator passwd\"", username, username)
+	}
+	if _, err := checkUserPassword(user); err != nil {
+		return err
+	}
 	if err := s.Cfg.SetUser(username); err != nil {
 		return fmt.Errorf("error setting user: %v", err)
 	}
@@ -78,24 +272,27 @@ func HandlerLogin(s *State, cmd Command) error {
 	return nil
 }
 
-func HandlerRegister(s *State, cmd Command) error {
-	if cmd.Name == "" || len(cmd.Args) == 0 {
-		return errors.New("register command requires a username")
-	}
+func HandlerRegister(ctx context.Context, s *State, cmd Command) error {
 	username := cmd.Args[0]
 
-	_, err := s.DB.GetUser(context.Background(), username)
+	_, err := s.DB.GetUser(ctx, username)
 	if err == nil {
 		return fmt.Errorf("user %s already exists", username)
-	} else if err != sql.ErrNoRows {
+	}
+	if err != sql.ErrNoRows {
 		return fmt.Errorf("unexpected error checking user: %v", err)
 	}
+	_, hash, err := readNewPassword(passwordEnv)
+	if err != nil {
+		return err
+	}
 
-	user, err := s.DB.CreateUser(context.Background(), database.CreateUserParams{
-		ID:        uuid.New(),
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-		Name:      username,
+	user, err := s.DB.CreateUser(ctx, database.CreateUserParams{
+		ID:           uuid.New(),
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+		Name:         username,
+		PasswordHash: sql.NullString{String: hash, Valid: true},
 	})
 	if err != nil {
 		return fmt.Errorf("error creating user: %v", err)
@@ -109,11 +306,8 @@ func HandlerRegister(s *State, cmd Command) error {
 
 }
 
-func HandlerReset(s *State, cmd Command) error {
-	if len(cmd.Args) != 0 {
-		return errors.New("reset command does not take any arguments")
-	}
-	err := s.DB.DeleteAllUsers(context.Background())
+func HandlerReset(ctx context.Context, s *State, cmd Command) error {
+	err := s.DB.DeleteAllUsers(ctx)
 	if err != nil {
 		return fmt.Errorf("error deleting all users: %v", err)
 	}
@@ -124,68 +318,193 @@ func HandlerReset(s *State, cmd Command) error {
 	return nil
 }
 
-func HandlerUsers(s *State, cmd Command) error {
-	if len(cmd.Args) != 0 {
-		return errors.New("users command does not take any arguments")
-	}
-	users, err := s.DB.GetAllUsers(context.Background())
+func HandlerUsers(ctx context.Context, s *State, cmd Command) error {
+	users, err := s.DB.GetAllUsers(ctx)
 	if err != nil {
 		return fmt.Errorf("error retrieving users: %v", err)
 	}
-	if len(users) == 0 {
-		fmt.Println("No users found.")
-		return nil
+	records := Records{
+		Columns: []string{"id", "name", "created_at", "current"},
+		Empty:   "No users found.",
 	}
-	fmt.Println("Users:")
 	for _, user := range users {
-		output := "* " + user.Name
-		if user.Name == s.Cfg.CurrentUser {
-			output += " (current)"
-		}
-		fmt.Println(output)
+		records.Add(user.ID, user.Name, user.CreatedAt, user.Name == s.Cfg.CurrentUser)
 	}
+	return s.Emit(records)
+}
 
-	return nil
+// drainTimeout is how long a daemon gets to finish the work in flight
+// when asked to stop.
+const drainTimeout = 30 * time.Second
+
+type aggOptions struct {
+	DigestEvery time.Duration
+	// Workers is how many feeds are fetched at once
+	Workers int
+	// LeaseOwner names this aggregator in the feed leases it takes
+	LeaseOwner string
 }
 
-func HandlerAgg(s *State, cmd Command) error {
-	if len(cmd.Args) != 1 {
-		return errors.New("agg command requires a timeout duration in seconds")
-	}
+func HandlerAgg(ctx context.Context, s *State, cmd Command) error {
 	time_between_reqs, err := time.ParseDuration(cmd.Args[0] + "s")
 	if err != nil {
 		return fmt.Errorf("error parsing duration: %v", err)
 	}
+	opts := aggOptions{Workers: cmd.FlagInt("workers")}
+	opts.DigestEvery, err = time.ParseDuration(cmd.FlagString("digest"))
+	if err != nil {
+		return fmt.Errorf("error parsing digest interval: %v", err)
+	}
+	if opts.Workers < 1 {
+		return errors.New("--workers must be at least 1")
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	opts.LeaseOwner = fmt.Sprintf("%s:%d", host, os.Getpid())
+	daemonMode := cmd.FlagBool("daemon")
+	if daemonMode && s.LogFormat == "" {
+		s.Log, _ = NewLogger(os.Stderr, LogJSON, s.LogLevel)
+	}
+	metricsAddr := cmd.FlagString("metrics-addr")
+	if metricsAddr != "" {
+		s.Metrics = newAggMetrics(s, time_between_reqs)
+		s.DB = database.New(s.Metrics.db(s.Conn))
+	}
+	// Fail fast on a bad configuration instead of on every tick
+	if err := checkAggConfig(s, opts.DigestEvery); err != nil {
+		return err
+	}
+
+	// Feed leases already keep aggregators sharing the database apart, so
+	// the lock file is only for those who want a single one per machine
+	lockPath := cmd.FlagString("lock-file")
+	if lockPath == "" && cmd.FlagBool("lock") {
+		lockPath = daemon.LockPath(s.Cfg.DBURL)
+	}
+	if lockPath != "" {
+		lock, err := daemon.Acquire(lockPath)
+		if err != nil {
+			return err
+		}
+		defer lock.Release()
+	}
+
+	if metricsAddr != "" {
+		srv, err := s.Metrics.serve(s, metricsAddr)
+		if err != nil {
+			return err
+		}
+		defer srv.Close()
+	}
+
+	// Work gets its own context. Ctrl+C interrupts it at once, but a daemon
+	// told to stop lets the current fetch finish, for up to drainTimeout.
+	work, cancelWork := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelWork()
+	context.AfterFunc(ctx, func() {
+		if daemonMode {
+			time.AfterFunc(drainTimeout, cancelWork)
+		} else {
+			cancelWork()
+		}
+	})
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
 	ticker := time.NewTicker(time_between_reqs)
 	defer ticker.Stop()
-
-	// Create a context that gets canceled on Ctrl+C
-	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
-	defer stop()
+	s.Log.Info("Aggregator started", "interval", time_between_reqs.String(), "workers", opts.Workers,
+		"lease_owner", opts.LeaseOwner, "lock_file", lockPath)
+	if err := daemon.Notify("READY=1"); err != nil {
+		s.Log.Warn("systemd notification failed", "error", err)
+	}
 
 	for {
 		select {
 		case <-ctx.Done():
-			fmt.Println("\nAggregator stopped.")
+			daemon.Notify("STOPPING=1")
+			s.Log.Info("Aggregator stopped")
 			return nil
+		case <-hup:
+			daemon.Notify("RELOADING=1")
+			if err := reloadAggConfig(s, opts.DigestEvery); err != nil {
+				s.Log.Error("Config reload failed, keeping the previous config", "error", err)
+			} else {
+				s.Log.Info("Config reloaded")
+			}
+			daemon.Notify("READY=1")
 		case <-ticker.C:
-			if err := scrapeFeeds(s); err != nil {
-				fmt.Println("Error scraping feeds:", err)
+			aggTick(work, s, opts)
+		}
+	}
+}
+
+// aggTick does one round of agg's work. Errors are logged rather than
+// returned, so one bad feed doesn't stop the aggregator.
+func aggTick(ctx context.Context, s *State, opts aggOptions) {
+	var wg sync.WaitGroup
+	for range opts.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := scrapeFeeds(ctx, s, opts.LeaseOwner); err != nil && ctx.Err() == nil {
+				s.Log.Error("Scraping feeds failed", "error", err)
 			}
+		}()
+	}
+	wg.Wait()
+	if err := deliverWebhooks(ctx, s); err != nil && ctx.Err() == nil {
+		s.Log.Error("Delivering webhooks failed", "error", err)
+	}
+	if opts.DigestEvery > 0 {
+		if err := sendDigests(ctx, s, opts.DigestEvery, false); err != nil && ctx.Err() == nil {
+			s.Log.Error("Sending digests failed", "error", err)
 		}
 	}
+	if err := renewWebSub(ctx, s); err != nil && ctx.Err() == nil {
+		s.Log.Error("Renewing WebSub subscriptions failed", "error", err)
+	}
 }
 
-func HandlerAddFeeds(s *State, cmd Command, user database.User) error {
-	if len(cmd.Args) < 2 {
-		return errors.New("feeds command requires a name and URL")
-	} else if len(cmd.Args) > 2 {
-		return errors.New("feeds command takes only a name and URL")
+func checkAggConfig(s *State, digestEvery time.Duration) error {
+	if _, err := s.feedFetcher(); err != nil {
+		return err
+	}
+	if digestEvery > 0 {
+		return checkSMTPConfig(s)
+	}
+	return nil
+}
+
+// reloadAggConfig rereads the config file for SIGHUP, keeping the current
+// config if the new one is unusable.
+func reloadAggConfig(s *State, digestEvery time.Duration) error {
+	cfg, err := config.Read()
+	if err != nil {
+		return fmt.Errorf("error reading config: %v", err)
 	}
+	if cfg.DBURL != s.Cfg.DBURL {
+		return errors.New("db_url can't change without a restart")
+	}
+	old, oldFetcher := *s.Cfg, s.Fetcher
+	*s.Cfg = cfg
+	s.Fetcher = nil
+	if err := checkAggConfig(s, digestEvery); err != nil {
+		*s.Cfg, s.Fetcher = old, oldFetcher
+		return err
+	}
+	return nil
+}
+
+func HandlerAddFeeds(ctx context.Context, s *State, cmd Command, user database.User) error {
 	name := cmd.Args[0]
 	url := cmd.Args[1]
 
-	feed, err := s.DB.CreateFeed(context.Background(), database.CreateFeedParams{
+	feed, err := s.DB.CreateFeed(ctx, database.CreateFeedParams{
 		Name:   name,
 		Url:    url,
 		UserID: user.ID,
@@ -194,7 +513,7 @@ func HandlerAddFeeds(s *State, cmd Command, user database.User) error {
 		return fmt.Errorf("error creating feed: %v", err)
 	}
 	fmt.Printf("Feed added:\n- ID: %s\n- Name: %s\n- URL: %s\n", feed.ID, feed.Name, feed.Url)
-	if _, err := s.DB.CreateFeedFollow(context.Background(), database.CreateFeedFollowParams{
+	if _, err := s.DB.CreateFeedFollow(ctx, database.CreateFeedFollowParams{
 		FeedID: feed.ID,
 		UserID: user.ID,
 	}); err != nil {
@@ -203,32 +522,25 @@ func HandlerAddFeeds(s *State, cmd Command, user database.User) error {
 	return nil
 }
 
-func HandlerFeeds(s *State, cmd Command) error {
-	if len(cmd.Args) != 0 {
-		return errors.New("feeds command does not take any arguments")
-	}
+func HandlerFeeds(ctx context.Context, s *State, cmd Command) error {
 
-	feeds, err := s.DB.GetAllFeeds(context.Background())
+	feeds, err := s.DB.GetAllFeeds(ctx)
 	if err != nil {
 		return fmt.Errorf("error retrieving feeds: %v", err)
 	}
-	if len(feeds) == 0 {
-		fmt.Println("No feeds found.")
-		return nil
+	records := Records{
+		Columns: []string{"id", "name", "url", "added_by", "created_at"},
+		Empty:   "No feeds found.",
 	}
 	for _, feed := range feeds {
-		output := fmt.Sprintf("* %s (%s), - Added by %s", feed.Name, feed.Url, feed.UserName)
-		fmt.Println(output)
+		records.Add(feed.ID, feed.Name, feed.Url, feed.UserName, feed.CreatedAt)
 	}
-	return nil
+	return s.Emit(records)
 }
 
-func HandlerFollow(s *State, cmd Command, user database.User) error {
-	if len(cmd.Args) != 1 {
-		return errors.New("follow command requires a feed URL")
-	}
+func HandlerFollow(ctx context.Context, s *State, cmd Command, user database.User) error {
 	feedURL := cmd.Args[0]
-	feed, err := s.DB.GetFeedByUrl(context.Background(), feedURL)
+	feed, err := s.DB.GetFeedByUrl(ctx, feedURL)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return fmt.Errorf("feed with URL %s does not exist", feedURL)
@@ -236,7 +548,7 @@ func HandlerFollow(s *State, cmd Command, user database.User) error {
 		return fmt.Errorf("error checking feed: %v", err)
 	}
 
-	follow, err := s.DB.CreateFeedFollow(context.Background(), database.CreateFeedFollowParams{
+	follow, err := s.DB.CreateFeedFollow(ctx, database.CreateFeedFollowParams{
 		FeedID: feed.ID,
 		UserID: user.ID,
 	})
@@ -247,11 +559,8 @@ func HandlerFollow(s *State, cmd Command, user database.User) error {
 	return nil
 }
 
-func HandlerFollowing(s *State, cmd Command, user database.User) error {
-	if len(cmd.Args) != 0 {
-		return errors.New("following command does not take any arguments")
-	}
-	user, err := s.DB.GetUser(context.Background(), s.Cfg.CurrentUser)
+func HandlerFollowing(ctx context.Context, s *State, cmd Command, user database.User) error {
+	user, err := s.DB.GetUser(ctx, s.Cfg.CurrentUser)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return fmt.Errorf("current user %s does not exist", s.Cfg.CurrentUser)
@@ -259,27 +568,23 @@ func HandlerFollowing(s *State, cmd Command, user database.User) error {
 		return fmt.Errorf("error checking current user: %v", err)
 	}
 
-	follows, err := s.DB.GetFeedFollowsForUser(context.Background(), user.ID)
+	follows, err := s.DB.GetFeedFollowsForUser(ctx, user.ID)
 	if err != nil {
 		return fmt.Errorf("error retrieving followed feeds: %v", err)
 	}
-	if len(follows) == 0 {
-		fmt.Println("You are not following any feeds.")
-		return nil
+	records := Records{
+		Columns: []string{"feed_id", "feed", "url", "followed_at"},
+		Empty:   "You are not following any feeds.",
 	}
-	fmt.Println("Feeds you are following:")
 	for _, follow := range follows {
-		fmt.Printf("* %s (%s)\n", follow.FeedName, follow.FeedUrl)
+		records.Add(follow.FeedID, follow.FeedName, follow.FeedUrl, follow.CreatedAt)
 	}
-	return nil
+	return s.Emit(records)
 }
 
-func HandlerUnfollow(s *State, cmd Command, user database.User) error {
-	if len(cmd.Args) != 1 {
-		return errors.New("unfollow command requires a feed URL")
-	}
+func HandlerUnfollow(ctx context.Context, s *State, cmd Command, user database.User) error {
 	feedURL := cmd.Args[0]
-	feed, err := s.DB.GetFeedByUrl(context.Background(), feedURL)
+	feed, err := s.DB.GetFeedByUrl(ctx, feedURL)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return fmt.Errorf("feed with URL %s does not exist", feedURL)
@@ -287,97 +592,139 @@ func HandlerUnfollow(s *State, cmd Command, user database.User) error {
 		return fmt.Errorf("error checking feed: %v", err)
 	}
 
-	err = s.DB.DeleteFeedFollow(context.Background(), database.DeleteFeedFollowParams{
+	deleted, err := s.DB.DeleteFeedFollow(ctx, database.DeleteFeedFollowParams{
 		FeedID: feed.ID,
 		UserID: user.ID,
 	})
 	if err != nil {
 		return fmt.Errorf("error unfollowing feed: %v", err)
 	}
+	if deleted == 0 {
+		return fmt.Errorf("you are not following feed %s", feedURL)
+	}
 	fmt.Printf("Successfully unfollowed feed %s (%s)\n", feed.Name, feed.Url)
 	return nil
 }
 
-func HandlerBrowse(s *State, cmd Command, user database.User) error {
-	if len(cmd.Args) > 1 {
-		return errors.New("browse command takes at most one optional argument for limit")
-	}
-	var limit int
-	if len(cmd.Args) == 1 {
-		parsedLimit, err := strconv.Atoi(cmd.Args[0])
-		if err != nil || parsedLimit <= 0 {
-			return errors.New("limit must be a positive integer")
+func HandlerAlias(ctx context.Context, s *State, cmd Command, user database.User) error {
+	feedURL := cmd.Args[0]
+	alias := strings.TrimSpace(cmd.Args[1])
+	feed, err := s.DB.GetFeedByUrl(ctx, feedURL)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("feed with URL %s does not exist", feedURL)
 		}
-		limit = parsedLimit
-	} else {
-		limit = 2 // default if not provided
+		return fmt.Errorf("error checking feed: %v", err)
 	}
-	posts, err := s.DB.GetPostsForUser(context.Background(), database.GetPostsForUserParams{
+
+	// An empty name clears the alias and falls back to the feed's global name
+	_, err = s.DB.SetFeedFollowAlias(ctx, database.SetFeedFollowAliasParams{
+		FeedID: feed.ID,
 		UserID: user.ID,
-		Limit:  int32(limit),
+		Alias:  sql.NullString{String: alias, Valid: alias != ""},
 	})
 	if err != nil {
-		return fmt.Errorf("error retrieving posts: %v", err)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("you are not following feed %s", feedURL)
+		}
+		return fmt.Errorf("error setting alias: %v", err)
 	}
-	if len(posts) == 0 {
-		fmt.Println("No posts found for the current user.")
+	if alias == "" {
+		fmt.Printf("Cleared alias for feed %s (%s)\n", feed.Name, feed.Url)
 		return nil
 	}
-	for _, post := range posts {
-		fmt.Printf("- %s (%s)\n", post.Title, post.Url)
-	}
+	fmt.Printf("Feed %s (%s) will be shown as %q\n", feed.Name, feed.Url, alias)
 	return nil
 }
 
-func scrapeFeeds(s *State) error {
-	// Get next feed
-	nextfeed, err := s.DB.GetNextFeedToFetch(context.Background())
-	if err != nil {
-		if err == sql.ErrNoRows {
-			fmt.Println("No feeds to fetch at this time.")
-			return nil
+func HandlerBrowse(ctx context.Context, s *State, cmd Command, user database.User) error {
+	limit := 2 // default if not provided
+	if len(cmd.Args) == 1 {
+		parsedLimit, err := strconv.Atoi(cmd.Args[0])
+		if err != nil || parsedLimit <= 0 {
+			return errors.New("limit must be a positive integer")
 		}
-		return fmt.Errorf("error retrieving next feed to fetch: %v", err)
-	}
-	err = s.DB.MarkFeedFetched(context.Background(), nextfeed.ID)
-	if err != nil {
-		return fmt.Errorf("error marking feed as fetched: %v", err)
+		limit = parsedLimit
 	}
-	feed, err := rss.FetchFeed(context.Background(), nextfeed.Url)
+	rules, err := loadUserRules(ctx, s, user.ID)
 	if err != nil {
-		return fmt.Errorf("error fetching feed %s: %v", nextfeed.Url, err)
-	}
-	fmt.Printf("Fetched feed: %s\n", nextfeed.Name)
-
-	layout := "Mon, 02 Jan 2006 15:04:05 MST" // common RSS time format
-
-	// Loop through each item (post) in the feed
-	for _, item := range feed.Channel.Item {
-		fmt.Printf("- %s\n", item.Title)
-
-		// Parse the pubDate string
-		publishedTime, err := time.Parse(layout, item.PubDate)
-		if err != nil {
-			// fallback or zero time is okay
-			publishedTime = time.Time{}
-		}
-
-		// Attempt to insert the post
-		_, err = s.DB.CreatePost(context.Background(), database.CreatePostParams{
-			Title:       item.Title,
-			Url:         item.Link,
-			Description: sql.NullString{String: item.Description, Valid: item.Description != ""},
-			PublishedAt: sql.NullTime{Time: publishedTime, Valid: !publishedTime.IsZero()},
-			FeedID:      nextfeed.ID,
+		return err
+	}
+
+	// Rules added after a post was stored still apply here, so page through
+	// posts until enough of them survive the hide rules.
+	var posts []database.GetPostsForUserRow
+	offset := 0
+	for len(posts) < limit {
+		page, err := s.DB.GetPostsForUser(ctx, database.GetPostsForUserParams{
+			UserID:    user.ID,
+			Tag:       optionalString(cmd.FlagString("tag")),
+			Author:    optionalString(cmd.FlagString("author")),
+			RowLimit:  int32(limit),
+			RowOffset: int32(offset),
 		})
-
 		if err != nil {
-			// Ignore duplicate URL errors (violating unique constraint)
-			if strings.Contains(err.Error(), "duplicate key") {
+			return fmt.Errorf("error retrieving posts: %v", err)
+		}
+		for _, post := range page {
+			res := filter.Apply(rules, filterItem(post))
+			if res.Any() {
+				if err := savePostState(ctx, s.DB, user.ID, post.ID, res); err != nil {
+					return err
+				}
+			}
+			if res.Hide {
+				// Now hidden, so it drops out of the next page's offset
 				continue
 			}
-			fmt.Println("Error inserting post:", err)
+			offset++
+			post.Read = post.Read || res.MarkRead
+			post.Starred = post.Starred || res.Star
+			if len(posts) < limit {
+				posts = append(posts, post)
+			}
+		}
+		if len(page) < limit {
+			break
 		}
 	}
-	return nil
+	records := Records{
+		Columns: []string{"id", "feed", "title", "url", "published_at", "author", "tags", "comments_url", "read", "starred",
+			"thumbnail_url", "thumbnail_width", "thumbnail_height"},
+		Empty: "No posts found for the current user.",
+	}
+	for _, post := range posts {
+		tags := sql.NullString{String: strings.Join(post.Tags, ", "), Valid: len(post.Tags) > 0}
+		records.Add(post.ID, post.FeedName, post.Title, post.Url, post.PublishedAt, post.Author, tags, post.CommentsUrl, post.Read, post.Starred,
+			post.ThumbnailUrl, post.ThumbnailWidth, post.ThumbnailHeight)
+	}
+	return s.Emit(records)
+}
+
+// optionalString turns an unset flag into SQL NULL.
+func optionalString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func HandlerEnclosures(ctx context.Context, s *State, cmd Command, user database.User) error {
+	limit := cmd.FlagInt("limit")
+	if limit <= 0 {
+		return errors.New("limit must be a positive integer")
+	}
+	enclosures, err := s.DB.GetEnclosuresForUser(ctx, database.GetEnclosuresForUserParams{
+		UserID: user.ID,
+		Limit:  int32(limit),
+	})
+	if err != nil {
+		return fmt.Errorf("error retrieving enclosures: %v", err)
+	}
+	records := Records{
+		Columns: []string{"post_id", "feed", "post", "published_at", "url", "type", "length", "duration", "season", "episode", "explicit"},
+		Empty:   "No enclosures found.",
+	}
+	for _, e := range enclosures {
+		records.Add(e.PostID, e.FeedName, e.PostTitle, e.PublishedAt, e.Url, e.Type, e.Length,
+			e.DurationSeconds, e.Season, e.Episode, e.Explicit)
+	}
+	return s.Emit(records)
 }
@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/JadedPigeon/Gator/internal/config"
+	"github.com/JadedPigeon/Gator/internal/rss"
+)
+
+func newTestCommands(name string, info CommandInfo, handler func(context.Context, *State, Command) error) *Commands {
+	c := &Commands{
+		Handlers: make(map[string]func(context.Context, *State, Command) error),
+		Info:     make(map[string]CommandInfo),
+	}
+	c.Register(name, handler, info)
+	return c
+}
+
+func TestRunAppliesCommandTimeout(t *testing.T) {
+	// A feed that never finishes, fetched by a command with a short timeout
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	s := &State{Cfg: &config.Config{
+		CommandTimeout: config.Duration{Duration: 100 * time.Millisecond},
+		FetchTimeout:   config.Duration{Duration: time.Minute},
+	}}
+	cmds := newTestCommands("fetch", CommandInfo{}, func(ctx context.Context, s *State, cmd Command) error {
+		fetcher, err := s.feedFetcher()
+		if err != nil {
+			return err
+		}
+		_, err = fetcher.FetchStream(ctx, srv.URL, func(rss.RSSItem) error { return nil })
+		return err
+	})
+
+	start := time.Now()
+	err := cmds.Run(context.Background(), s, Command{Name: "fetch"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("command took %v, want about the 100ms timeout", elapsed)
+	}
+}
+
+func TestRunLongRunningHasNoTimeout(t *testing.T) {
+	s := &State{Cfg: &config.Config{CommandTimeout: config.Duration{Duration: time.Millisecond}}}
+	cmds := newTestCommands("agg", CommandInfo{LongRunning: true}, func(ctx context.Context, s *State, cmd Command) error {
+		if _, ok := ctx.Deadline(); ok {
+			return errors.New("long-running command got a deadline")
+		}
+		return nil
+	})
+	if err := cmds.Run(context.Background(), s, Command{Name: "agg"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRunDefaultCommandTimeout(t *testing.T) {
+	s := &State{Cfg: &config.Config{}}
+	cmds := newTestCommands("users", CommandInfo{}, func(ctx context.Context, s *State, cmd Command) error {
+		deadline, ok := ctx.Deadline()
+		if !ok {
+			return errors.New("command has no deadline")
+		}
+		if left := time.Until(deadline); left > defaultCommandTimeout || left < defaultCommandTimeout-time.Second {
+			return errors.New("deadline doesn't match the default command timeout")
+		}
+		return nil
+	})
+	if err := cmds.Run(context.Background(), s, Command{Name: "users"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDigestIsLongRunning(t *testing.T) {
+	// digest applies the command timeout to each email instead
+	if !NewCommands().Info["digest"].LongRunning {
+		t.Error("digest would be cut off by the command timeout")
+	}
+}
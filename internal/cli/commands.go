@@ -0,0 +1,243 @@
+package cli
+
+import (
+	"context"
+	"flag"
+)
+
+// NewCommands returns a registry with every built-in command registered.
+func NewCommands() *Commands {
+	c := &Commands{
+		Handlers: make(map[string]func(context.Context, *State, Command) error),
+		Info:     make(map[string]CommandInfo),
+	}
+
+	c.Register("help", c.HandlerHelp, CommandInfo{
+		Usage:       "[command]",
+		Description: "Show all commands, or details about one command",
+		MaxArgs:     1,
+		Standalone:  true,
+	})
+	c.Register("completion", c.HandlerCompletion, CommandInfo{
+		Usage:       "<bash|zsh|fish>",
+		Description: "Print a shell completion script",
+		MinArgs:     1,
+		MaxArgs:     1,
+		Standalone:  true,
+	})
+	c.Register("login", HandlerLogin, CommandInfo{
+		Usage:       "<username>",
+		Description: "Log in as a user, checking their password",
+		MinArgs:     1,
+		MaxArgs:     1,
+	})
+	c.Register("register", HandlerRegister, CommandInfo{
+		Usage:       "<username>",
+		Description: "Create a user with a password, or set one for a user without, and log in as them",
+		MinArgs:     1,
+		MaxArgs:     1,
+	})
+	c.Register("passwd", MiddlewareLoggedIn(HandlerPasswd), CommandInfo{
+		Description: "Set or change your password",
+	})
+	c.Register("token-create", MiddlewareLoggedIn(HandlerTokenCreate), CommandInfo{
+		Usage:       "<name>",
+		Description: "Create an API token for the server modes",
+		MinArgs:     1,
+		MaxArgs:     1,
+	})
+	c.Register("tokens", MiddlewareLoggedIn(HandlerTokens), CommandInfo{
+		Description: "List your API tokens",
+	})
+	c.Register("token-revoke", MiddlewareLoggedIn(HandlerTokenRevoke), CommandInfo{
+		Usage:       "<token-id>",
+		Description: "Revoke an API token",
+		MinArgs:     1,
+		MaxArgs:     1,
+	})
+	c.Register("fever-enable", MiddlewareLoggedIn(HandlerFeverEnable), CommandInfo{
+		Description: "Let Fever-compatible apps sign in as you",
+	})
+	c.Register("fever-disable", MiddlewareLoggedIn(HandlerFeverDisable), CommandInfo{
+		Description: "Turn off Fever sign-in",
+	})
+	c.Register("reset", HandlerReset, CommandInfo{
+		Description: "Delete all users and everything they own",
+	})
+	c.Register("users", HandlerUsers, CommandInfo{
+		Description: "List all users",
+	})
+	c.Register("agg", HandlerAgg, CommandInfo{
+		Usage:       "<seconds>",
+		Description: "Fetch feeds continuously, one feed per worker every interval",
+		MinArgs:     1,
+		MaxArgs:     1,
+		LongRunning: true,
+		SetFlags: func(fs *flag.FlagSet) {
+			fs.Duration("digest", 0, "also email digests this often, e.g. 24h (0 disables)")
+			fs.Int("workers", 1, "number of feeds to fetch at once each interval")
+			fs.String("metrics-addr", "", "serve Prometheus metrics at /metrics on this address, e.g. localhost:9090")
+			fs.Bool("daemon", false, "run as a service: log JSON, finish work in flight on SIGTERM, notify systemd")
+			fs.Bool("lock", false, "refuse to start if another aggregator for the same database runs on this machine")
+			fs.String("lock-file", "", "lock file to use, implies --lock (default: per database, in $XDG_RUNTIME_DIR)")
+		},
+	})
+	c.Register("digest", HandlerDigest, CommandInfo{
+		Description: "Email each user their unread posts since the last digest",
+		LongRunning: true,
+		SetFlags: func(fs *flag.FlagSet) {
+			fs.Bool("dry-run", false, "print the emails instead of sending them")
+		},
+	})
+	c.Register("digest-enable", MiddlewareLoggedIn(HandlerDigestEnable), CommandInfo{
+		Usage:       "<email>",
+		Description: "Receive email digests at this address",
+		MinArgs:     1,
+		MaxArgs:     1,
+	})
+	c.Register("digest-disable", MiddlewareLoggedIn(HandlerDigestDisable), CommandInfo{
+		Description: "Stop receiving email digests",
+	})
+	c.Register("websub", HandlerWebSub, CommandInfo{
+		Description: "List WebSub push subscriptions and their state",
+	})
+	c.Register("addfeed", MiddlewareLoggedIn(HandlerAddFeeds), CommandInfo{
+		Usage:       "<name> <url>",
+		Description: "Add a feed and follow it",
+		MinArgs:     2,
+		MaxArgs:     2,
+	})
+	c.Register("feeds", HandlerFeeds, CommandInfo{
+		Description: "List all feeds",
+	})
+	c.Register("follow", MiddlewareLoggedIn(HandlerFollow), CommandInfo{
+		Usage:       "<feed-url>",
+		Description: "Follow an existing feed",
+		MinArgs:     1,
+		MaxArgs:     1,
+	})
+	c.Register("following", MiddlewareLoggedIn(HandlerFollowing), CommandInfo{
+		Description: "List the feeds you follow",
+	})
+	c.Register("unfollow", MiddlewareLoggedIn(HandlerUnfollow), CommandInfo{
+		Usage:       "<feed-url>",
+		Description: "Stop following a feed",
+		MinArgs:     1,
+		MaxArgs:     1,
+	})
+	c.Register("alias", MiddlewareLoggedIn(HandlerAlias), CommandInfo{
+		Usage:       "<feed-url> <name>",
+		Description: "Show a followed feed under your own name (empty name clears it)",
+		MinArgs:     2,
+		MaxArgs:     2,
+	})
+	c.Register("browse", MiddlewareLoggedIn(HandlerBrowse), CommandInfo{
+		Usage:       "[limit]",
+		Description: "Show the latest posts from the feeds you follow",
+		MaxArgs:     1,
+		SetFlags: func(fs *flag.FlagSet) {
+			fs.String("tag", "", "only show posts in this category")
+			fs.String("author", "", "only show posts whose author contains this text")
+		},
+	})
+	c.Register("enclosures", MiddlewareLoggedIn(HandlerEnclosures), CommandInfo{
+		Description: "List media files (e.g. podcast audio) attached to posts you follow",
+		SetFlags: func(fs *flag.FlagSet) {
+			fs.Int("limit", 20, "number of enclosures to show")
+		},
+	})
+	c.Register("export-feed", MiddlewareLoggedIn(HandlerExportFeed), CommandInfo{
+		Description: "Print your timeline as an RSS or Atom feed",
+		Document:    true,
+		SetFlags: func(fs *flag.FlagSet) {
+			fs.String("format", "rss", "feed format: rss or atom")
+			fs.String("tag", "", "only include posts in this category")
+			fs.Int("limit", 50, "number of posts to include")
+			fs.String("link", "http://localhost:8081/", "page the feed links to")
+		},
+	})
+	c.Register("download", MiddlewareLoggedIn(HandlerDownload), CommandInfo{
+		Usage:       "[post-id|post-url]",
+		Description: "Download the enclosures of a post, or the latest episodes of a feed",
+		MaxArgs:     1,
+		LongRunning: true,
+		SetFlags: func(fs *flag.FlagSet) {
+			fs.String("feed", "", "download the latest episodes of the feed with this URL")
+			fs.Int("latest", 1, "number of episodes to download with --feed")
+		},
+	})
+	c.Register("downloads", MiddlewareLoggedIn(HandlerDownloads), CommandInfo{
+		Description: "List the episodes you have downloaded",
+	})
+	c.Register("serve", HandlerServe, CommandInfo{
+		Description: "Serve a JSON API, authenticated with API tokens",
+		LongRunning: true,
+		SetFlags: func(fs *flag.FlagSet) {
+			fs.String("addr", "localhost:8080", "address to listen on")
+		},
+	})
+	c.Register("web", HandlerWeb, CommandInfo{
+		Description: "Serve the web reader",
+		LongRunning: true,
+		SetFlags: func(fs *flag.FlagSet) {
+			fs.String("addr", "localhost:8081", "address to listen on")
+		},
+	})
+	c.Register("webhook-add", MiddlewareLoggedIn(HandlerWebhookAdd), CommandInfo{
+		Usage:       "<url>",
+		Description: "Send new posts to a URL as they arrive",
+		MinArgs:     1,
+		MaxArgs:     1,
+		SetFlags: func(fs *flag.FlagSet) {
+			fs.String("feed", "", "only send posts from the feed with this URL")
+			fs.String("category", "", "only send posts in this category")
+			fs.String("keyword", "", "only send posts whose title or description contains this text")
+			fs.String("template", "", "file with a text/template for the request body (default: JSON)")
+			fs.String("content-type", "", "Content-Type header for requests (default: application/json, or none with --template)")
+		},
+	})
+	c.Register("webhooks", MiddlewareLoggedIn(HandlerWebhooks), CommandInfo{
+		Description: "List your webhooks",
+	})
+	c.Register("webhook-delete", MiddlewareLoggedIn(HandlerWebhookDelete), CommandInfo{
+		Usage:       "<webhook-id>",
+		Description: "Delete a webhook",
+		MinArgs:     1,
+		MaxArgs:     1,
+	})
+	c.Register("webhook-log", MiddlewareLoggedIn(HandlerWebhookLog), CommandInfo{
+		Description: "Show recent webhook deliveries",
+		SetFlags: func(fs *flag.FlagSet) {
+			fs.Int("limit", 20, "number of deliveries to show")
+		},
+	})
+	c.Register("filter-add", MiddlewareLoggedIn(HandlerFilterAdd), CommandInfo{
+		Usage:       "<title|description|url|author> <substring|regex> <pattern> <hide|mark-read|star|alert>",
+		Description: "Add a filter rule for incoming posts",
+		MinArgs:     4,
+		MaxArgs:     4,
+		SetFlags: func(fs *flag.FlagSet) {
+			fs.String("feed", "", "only apply the rule to the feed with this URL")
+		},
+	})
+	c.Register("filters", MiddlewareLoggedIn(HandlerFilters), CommandInfo{
+		Description: "List your filter rules",
+	})
+	c.Register("filter-test", MiddlewareLoggedIn(HandlerFilterTest), CommandInfo{
+		Usage:       "<rule-id>",
+		Description: "Show which of your recent posts a filter rule matches",
+		MinArgs:     1,
+		MaxArgs:     1,
+		SetFlags: func(fs *flag.FlagSet) {
+			fs.Int("limit", 20, "number of recent posts to test against")
+		},
+	})
+	c.Register("filter-delete", MiddlewareLoggedIn(HandlerFilterDelete), CommandInfo{
+		Usage:       "<rule-id>",
+		Description: "Delete a filter rule",
+		MinArgs:     1,
+		MaxArgs:     1,
+	})
+
+	return c
+}
@@ -0,0 +1,182 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+func (c *Commands) HandlerCompletion(ctx context.Context, s *State, cmd Command) error {
+	switch cmd.Args[0] {
+	case "bash":
+		return c.writeBashCompletion(s.stdout())
+	case "zsh":
+		return c.writeZshCompletion(s.stdout())
+	case "fish":
+		return c.writeFishCompletion(s.stdout())
+	}
+	return fmt.Errorf("unsupported shell %q (expected bash, zsh or fish)", cmd.Args[0])
+}
+
+func (c *Commands) writeBashCompletion(w io.Writer) error {
+	fmt.Fprintln(w, "# bash completion for gator")
+	fmt.Fprintln(w, "_gator() {")
+	fmt.Fprintln(w, `    local cur="${COMP_WORDS[COMP_CWORD]}" cmd="" i`)
+	fmt.Fprintln(w, `    for ((i = 1; i < COMP_CWORD; i++)); do`)
+	fmt.Fprintln(w, `        case "${COMP_WORDS[i]}" in`)
+	if flags := valueFlags(c.Global); len(flags) > 0 {
+		fmt.Fprintf(w, "            %s) ((i++)) ;;\n", strings.Join(flags, "|"))
+	}
+	fmt.Fprintln(w, `            -*) ;;`)
+	fmt.Fprintln(w, `            *) cmd="${COMP_WORDS[i]}"; break ;;`)
+	fmt.Fprintln(w, `        esac`)
+	fmt.Fprintln(w, `    done`)
+	fmt.Fprintln(w, `    if [[ -z "$cmd" ]]; then`)
+	words := append(c.names(), flagNames(c.Global)...)
+	fmt.Fprintf(w, "        COMPREPLY=( $(compgen -W %s -- \"$cur\") )\n", shellQuote(strings.Join(words, " ")))
+	fmt.Fprintln(w, `        return`)
+	fmt.Fprintln(w, `    fi`)
+	fmt.Fprintln(w, `    case "$cmd" in`)
+	for _, name := range c.names() {
+		flags := flagNames(c.Info[name].flagSet(name))
+		if name == "help" {
+			flags = c.names()
+		}
+		if name == "completion" {
+			flags = []string{"bash", "zsh", "fish"}
+		}
+		if len(flags) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "        %s) COMPREPLY=( $(compgen -W %s -- \"$cur\") ) ;;\n", name, shellQuote(strings.Join(flags, " ")))
+	}
+	fmt.Fprintln(w, `    esac`)
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w, "complete -F _gator gator")
+	return nil
+}
+
+func (c *Commands) writeZshCompletion(w io.Writer) error {
+	fmt.Fprintln(w, "#compdef gator")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "_gator() {")
+	fmt.Fprintln(w, "    local -a commands")
+	fmt.Fprintln(w, "    commands=(")
+	for _, name := range c.names() {
+		fmt.Fprintf(w, "        %s\n", shellQuote(name+":"+c.Info[name].Description))
+	}
+	fmt.Fprintln(w, "    )")
+	fmt.Fprintln(w, "    local state")
+	fmt.Fprintln(w, "    _arguments -C \\")
+	for _, spec := range zshFlagSpecs(c.Global) {
+		fmt.Fprintf(w, "        %s \\\n", spec)
+	}
+	fmt.Fprintln(w, "        '1:command:->command' \\")
+	fmt.Fprintln(w, "        '*::arg:->args'")
+	fmt.Fprintln(w, "    case $state in")
+	fmt.Fprintln(w, "        command) _describe 'command' commands ;;")
+	fmt.Fprintln(w, "        args)")
+	fmt.Fprintln(w, "            case $words[1] in")
+	fmt.Fprintln(w, "                help) _describe 'command' commands ;;")
+	fmt.Fprintln(w, "                completion) _values 'shell' bash zsh fish ;;")
+	for _, name := range c.names() {
+		specs := zshFlagSpecs(c.Info[name].flagSet(name))
+		if len(specs) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "                %s) _arguments %s ;;\n", name, strings.Join(specs, " "))
+	}
+	fmt.Fprintln(w, "            esac ;;")
+	fmt.Fprintln(w, "    esac")
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `_gator "$@"`)
+	return nil
+}
+
+func (c *Commands) writeFishCompletion(w io.Writer) error {
+	fmt.Fprintln(w, "# fish completion for gator")
+	fmt.Fprintln(w, "complete -c gator -f")
+	for _, line := range fishFlagLines(c.Global, "__fish_use_subcommand") {
+		fmt.Fprintln(w, line)
+	}
+	for _, name := range c.names() {
+		fmt.Fprintf(w, "complete -c gator -n __fish_use_subcommand -a %s -d %s\n", name, shellQuote(c.Info[name].Description))
+	}
+	fmt.Fprintf(w, "complete -c gator -n '__fish_seen_subcommand_from help' -a %s\n", shellQuote(strings.Join(c.names(), " ")))
+	fmt.Fprintln(w, "complete -c gator -n '__fish_seen_subcommand_from completion' -a 'bash zsh fish'")
+	for _, name := range c.names() {
+		for _, line := range fishFlagLines(c.Info[name].flagSet(name), "__fish_seen_subcommand_from "+name) {
+			fmt.Fprintln(w, line)
+		}
+	}
+	return nil
+}
+
+func flagNames(fs *flag.FlagSet) []string {
+	var names []string
+	if fs == nil {
+		return names
+	}
+	fs.VisitAll(func(f *flag.Flag) {
+		names = append(names, "--"+f.Name)
+	})
+	return names
+}
+
+// valueFlags lists the flags that consume the following word as their value.
+func valueFlags(fs *flag.FlagSet) []string {
+	var names []string
+	if fs == nil {
+		return names
+	}
+	fs.VisitAll(func(f *flag.Flag) {
+		if !isBoolFlag(f) {
+			names = append(names, "--"+f.Name, "-"+f.Name)
+		}
+	})
+	return names
+}
+
+func zshFlagSpecs(fs *flag.FlagSet) []string {
+	var specs []string
+	if fs == nil {
+		return specs
+	}
+	fs.VisitAll(func(f *flag.Flag) {
+		usage := strings.NewReplacer("[", "(", "]", ")", ":", " ").Replace(f.Usage)
+		spec := "--" + f.Name + "[" + usage + "]"
+		if !isBoolFlag(f) {
+			spec += ":" + f.Name + ":"
+		}
+		specs = append(specs, shellQuote(spec))
+	})
+	return specs
+}
+
+func fishFlagLines(fs *flag.FlagSet, condition string) []string {
+	var lines []string
+	if fs == nil {
+		return lines
+	}
+	fs.VisitAll(func(f *flag.Flag) {
+		line := fmt.Sprintf("complete -c gator -n %s -l %s -d %s", shellQuote(condition), f.Name, shellQuote(f.Usage))
+		if !isBoolFlag(f) {
+			line += " -r"
+		}
+		lines = append(lines, line)
+	})
+	return lines
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// shellQuote wraps s in single quotes, which bash, zsh and fish all accept.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,198 @@
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	netmail "net/mail"
+	"time"
+
+	"github.com/JadedPigeon/Gator/internal/database"
+	"github.com/JadedPigeon/Gator/internal/digest"
+	"github.com/JadedPigeon/Gator/internal/mail"
+	"github.com/JadedPigeon/Gator/internal/rss"
+	"github.com/google/uuid"
+)
+
+// digestMaxPosts caps the posts listed in one email.
+const digestMaxPosts = 200
+
+func HandlerDigestEnable(ctx context.Context, s *State, cmd Command, user database.User) error {
+	addr, err := netmail.ParseAddress(cmd.Args[0])
+	if err != nil {
+		return fmt.Errorf("invalid email address %q", cmd.Args[0])
+	}
+	err = s.DB.SetDigestEmail(ctx, database.SetDigestEmailParams{
+		UserID: user.ID,
+		Email:  addr.Address,
+	})
+	if err != nil {
+		return fmt.Errorf("error enabling digests: %v", err)
+	}
+	fmt.Printf("Digests for %s will be sent to %s\n", user.Name, addr.Address)
+	return nil
+}
+
+func HandlerDigestDisable(ctx context.Context, s *State, cmd Command, user database.User) error {
+	n, err := s.DB.DeleteDigest(ctx, user.ID)
+	if err != nil {
+		return fmt.Errorf("error disabling digests: %v", err)
+	}
+	if n == 0 {
+		fmt.Printf("Digests were not enabled for %s\n", user.Name)
+		return nil
+	}
+	fmt.Printf("Digests disabled for %s\n", user.Name)
+	return nil
+}
+
+// HandlerDigest sends every user who enabled digests their unread posts
+// since the last one.
+func HandlerDigest(ctx context.Context, s *State, cmd Command) error {
+	dryRun := cmd.FlagBool("dry-run")
+	if !dryRun {
+		if err := checkSMTPConfig(s); err != nil {
+			return err
+		}
+	}
+	return sendDigests(ctx, s, 0, dryRun)
+}
+
+func checkSMTPConfig(s *State) error {
+	if s.Cfg.SMTP.Host == "" || s.Cfg.SMTP.From == "" {
+		return errors.New("digests need smtp.host and smtp.from in the config")
+	}
+	return nil
+}
+
+// sendDigests sends a digest to each user whose last one is at least
+// interval old. With dryRun the emails are written to s.Out instead and
+// nothing is recorded.
+func sendDigests(ctx context.Context, s *State, interval time.Duration, dryRun bool) error {
+	due, err := s.DB.GetDueDigests(ctx, int32(interval.Seconds()))
+	if err != nil {
+		return fmt.Errorf("error retrieving digests: %v", err)
+	}
+	for _, d := range due {
+		// Sending to many users can take a while, so instead of the whole
+		// run, each digest gets the command timeout
+		dctx, cancel := context.WithTimeout(ctx, s.Cfg.CommandTimeout.Or(defaultCommandTimeout))
+		err := sendDigest(dctx, s, d, interval, dryRun)
+		cancel()
+		if err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			// One bad address shouldn't hold up everyone else's
+			s.Log.Error("Sending digest failed", "user", d.UserName, "error", err)
+		}
+	}
+	return nil
+}
+
+// sendDigest runs in a transaction, so a failed send records nothing. The
+// posts it sends are recorded, and only those, so a post committed while
+// it runs goes in the next digest.
+func sendDigest(ctx context.Context, s *State, d database.GetDueDigestsRow, interval time.Duration, dryRun bool) error {
+	tx, err := s.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+	q := s.withTx(tx)
+
+	if !dryRun {
+		// Another aggregator may have picked the same digest
+		_, err := q.LockDueDigest(ctx, database.LockDueDigestParams{
+			UserID:          d.UserID,
+			IntervalSeconds: int32(interval.Seconds()),
+		})
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error locking digest: %v", err)
+		}
+	}
+
+	posts, err := q.GetDigestPosts(ctx, database.GetDigestPostsParams{
+		UserID: d.UserID,
+		Limit:  digestMaxPosts + 1,
+	})
+	if err != nil {
+		return fmt.Errorf("error retrieving posts: %v", err)
+	}
+	if len(posts) == 0 {
+		if dryRun {
+			fmt.Fprintf(s.Out, "No new posts for %s\n", d.UserName)
+			return nil
+		}
+		if err := q.MarkDigestSent(ctx, database.MarkDigestSentParams{UserID: d.UserID}); err != nil {
+			return fmt.Errorf("error recording digest: %v", err)
+		}
+		return tx.Commit()
+	}
+
+	dg := digest.Digest{UserName: d.UserName}
+	if len(posts) > digestMaxPosts {
+		posts = posts[:digestMaxPosts]
+		dg.Truncated = true
+	}
+	ids := make([]uuid.UUID, len(posts))
+	// Posts come sorted by feed name
+	for i, p := range posts {
+		ids[i] = p.ID
+		if len(dg.Feeds) == 0 || dg.Feeds[len(dg.Feeds)-1].Name != p.FeedName {
+			dg.Feeds = append(dg.Feeds, digest.Feed{Name: p.FeedName})
+		}
+		feed := &dg.Feeds[len(dg.Feeds)-1]
+		post := digest.Post{
+			Title:   p.Title,
+			URL:     p.Url,
+			Excerpt: rss.Excerpt(p.Description.String, 200),
+		}
+		if p.PublishedAt.Valid {
+			post.Published = p.PublishedAt.Time
+		}
+		feed.Posts = append(feed.Posts, post)
+	}
+	text, html, err := digest.Render(dg)
+	if err != nil {
+		return fmt.Errorf("error rendering digest: %v", err)
+	}
+	msg := &mail.Message{
+		From:    s.Cfg.SMTP.From,
+		To:      d.Email,
+		Subject: dg.Subject(),
+		Text:    text,
+		HTML:    html,
+	}
+
+	if dryRun {
+		body, err := msg.Bytes()
+		if err != nil {
+			return err
+		}
+		_, err = s.Out.Write(append(body, '\n'))
+		return err
+	}
+	err = mail.Send(ctx, mail.Config{
+		Host:        s.Cfg.SMTP.Host,
+		Port:        s.Cfg.SMTP.Port,
+		Username:    s.Cfg.SMTP.Username,
+		Password:    s.Cfg.SMTP.Password,
+		ImplicitTLS: s.Cfg.SMTP.ImplicitTLS,
+	}, msg)
+	if err != nil {
+		return err
+	}
+	if err := q.MarkDigestSent(ctx, database.MarkDigestSentParams{UserID: d.UserID, PostIds: ids}); err != nil {
+		return fmt.Errorf("error recording digest: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error recording digest: %v", err)
+	}
+	s.Log.Info("Sent digest", "user", d.UserName, "email", d.Email, "posts", dg.Count())
+	return nil
+}
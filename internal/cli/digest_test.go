@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"context"
+	"testing"
+
+	"github.com/JadedPigeon/Gator/internal/database"
+	"github.com/google/uuid"
+)
+
+// TestDigestPostFromOpenTransaction stores a post in a transaction that is
+// still open while a digest is sent. The post is older than the digest but
+// invisible to it, so it must go in the next digest instead.
+func TestDigestPostFromOpenTransaction(t *testing.T) {
+	s, feeds := aggState(t, 1, nil)
+	ctx := context.Background()
+	user, err := s.DB.GetUser(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.DB.CreateFeedFollow(ctx, database.CreateFeedFollowParams{FeedID: feeds[0].ID, UserID: user.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DB.SetDigestEmail(ctx, database.SetDigestEmailParams{UserID: user.ID, Email: "alice@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	createPost := func(q *database.Queries, title string) {
+		t.Helper()
+		_, err := q.CreatePosts(ctx, database.CreatePostsParams{
+			FeedID:       feeds[0].ID,
+			Titles:       []string{title},
+			Urls:         []string{"https://example.com/" + title},
+			Descriptions: []string{""},
+			PublishedAts: []string{""},
+			Authors:      []string{""},
+			CommentsUrls: []string{""},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// sendDigest without the email
+	digest := func() []string {
+		t.Helper()
+		tx, err := s.Conn.BeginTx(ctx, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tx.Rollback()
+		q := s.withTx(tx)
+		posts, err := q.GetDigestPosts(ctx, database.GetDigestPostsParams{UserID: user.ID, Limit: digestMaxPosts})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var titles []string
+		var ids []uuid.UUID
+		for _, p := range posts {
+			titles = append(titles, p.Title)
+			ids = append(ids, p.ID)
+		}
+		if err := q.MarkDigestSent(ctx, database.MarkDigestSentParams{UserID: user.ID, PostIds: ids}); err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+		return titles
+	}
+
+	ingest, err := s.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ingest.Rollback()
+	createPost(s.withTx(ingest), "late")
+	createPost(s.DB, "early")
+
+	if got := digest(); len(got) != 1 || got[0] != "early" {
+		t.Fatalf("first digest = %q, want [early]", got)
+	}
+	if err := ingest.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if got := digest(); len(got) != 1 || got[0] != "late" {
+		t.Fatalf("second digest = %q, want [late]", got)
+	}
+	if got := digest(); len(got) != 0 {
+		t.Fatalf("third digest = %q, want nothing", got)
+	}
+}
@@ -0,0 +1,223 @@
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/JadedPigeon/Gator/internal/database"
+	"github.com/JadedPigeon/Gator/internal/download"
+	"github.com/google/uuid"
+)
+
+// episodeTarget is one enclosure to download, whichever query found it.
+type episodeTarget struct {
+	EnclosureID uuid.UUID
+	URL         string
+	Type        string
+	PostTitle   string
+	FeedName    string
+}
+
+func HandlerDownload(ctx context.Context, s *State, cmd Command, user database.User) error {
+	targets, err := downloadTargets(ctx, s, cmd, user)
+	if err != nil {
+		return err
+	}
+	if len(targets) == 0 {
+		fmt.Println("No enclosures to download.")
+		return nil
+	}
+	dir, err := downloadDir(s)
+	if err != nil {
+		return err
+	}
+	fetcher, err := s.feedFetcher()
+	if err != nil {
+		return err
+	}
+
+	for _, t := range targets {
+		existing, err := s.DB.GetEpisodeDownload(ctx, database.GetEpisodeDownloadParams{
+			UserID:      user.ID,
+			EnclosureID: t.EnclosureID,
+		})
+		if err == nil {
+			if info, statErr := os.Stat(existing.Path); statErr == nil && info.Size() == existing.Size {
+				fmt.Printf("Already downloaded: %s\n", existing.Path)
+				continue
+			}
+		} else if err != sql.ErrNoRows {
+			return fmt.Errorf("error checking downloads: %v", err)
+		}
+
+		dest := episodePath(dir, t)
+		fmt.Printf("Downloading %s\n", t.URL)
+		res, err := download.File(ctx, fetcher, t.URL, dest)
+		if err != nil {
+			if ctx.Err() != nil {
+				return errors.New("download interrupted; run the command again to resume")
+			}
+			return fmt.Errorf("error downloading %s: %v", t.URL, err)
+		}
+		_, err = s.DB.SaveEpisodeDownload(ctx, database.SaveEpisodeDownloadParams{
+			UserID:      user.ID,
+			EnclosureID: t.EnclosureID,
+			Path:        res.Path,
+			Size:        res.Size,
+			Sha256:      res.SHA256,
+		})
+		if err != nil {
+			return fmt.Errorf("error recording download: %v", err)
+		}
+		resumed := ""
+		if res.Resumed {
+			resumed = ", resumed"
+		}
+		fmt.Printf("Saved %s (%d bytes%s, sha256 %s)\n", res.Path, res.Size, resumed, res.SHA256)
+	}
+	return nil
+}
+
+// downloadTargets resolves the command line to the enclosures to fetch:
+// either those of one post, given by ID or URL, or the latest of a feed.
+// Only feeds the user follows are searched.
+func downloadTargets(ctx context.Context, s *State, cmd Command, user database.User) ([]episodeTarget, error) {
+	var targets []episodeTarget
+	if feedURL := cmd.FlagString("feed"); feedURL != "" {
+		if len(cmd.Args) > 0 {
+			return nil, errors.New("give either a post or --feed, not both")
+		}
+		latest := cmd.FlagInt("latest")
+		if latest <= 0 {
+			return nil, errors.New("latest must be a positive integer")
+		}
+		feed, err := s.DB.GetFeedByUrl(ctx, feedURL)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return nil, fmt.Errorf("feed with URL %s does not exist", feedURL)
+			}
+			return nil, fmt.Errorf("error checking feed: %v", err)
+		}
+		following, err := s.DB.IsFollowingFeed(ctx, database.IsFollowingFeedParams{
+			FeedID: feed.ID,
+			UserID: user.ID,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error checking follows: %v", err)
+		}
+		if !following {
+			return nil, fmt.Errorf("you are not following feed %s", feedURL)
+		}
+		rows, err := s.DB.GetLatestEnclosuresForFeed(ctx, database.GetLatestEnclosuresForFeedParams{
+			FeedID: feed.ID,
+			Limit:  int32(latest),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error retrieving enclosures: %v", err)
+		}
+		for _, r := range rows {
+			targets = append(targets, episodeTarget{r.ID, r.Url, r.Type.String, r.PostTitle, r.FeedName})
+		}
+		return targets, nil
+	}
+
+	if len(cmd.Args) == 0 {
+		return nil, errors.New("give a post ID or URL, or --feed")
+	}
+	params := database.GetFollowedPostIDParams{Url: cmd.Args[0], UserID: user.ID}
+	if id, err := uuid.Parse(cmd.Args[0]); err == nil {
+		params.ID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+	postID, err := s.DB.GetFollowedPostID(ctx, params)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("post %s does not exist", cmd.Args[0])
+		}
+		return nil, fmt.Errorf("error retrieving post: %v", err)
+	}
+	rows, err := s.DB.GetEnclosuresForPost(ctx, database.GetEnclosuresForPostParams{
+		PostID: postID,
+		UserID: user.ID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving enclosures: %v", err)
+	}
+	for _, r := range rows {
+		targets = append(targets, episodeTarget{r.ID, r.Url, r.Type.String, r.PostTitle, r.FeedName})
+	}
+	return targets, nil
+}
+
+func HandlerDownloads(ctx context.Context, s *State, cmd Command, user database.User) error {
+	downloads, err := s.DB.GetEpisodeDownloadsForUser(ctx, user.ID)
+	if err != nil {
+		return fmt.Errorf("error retrieving downloads: %v", err)
+	}
+	records := Records{
+		Columns: []string{"feed", "post", "path", "size", "sha256", "downloaded_at"},
+		Empty:   "No downloads found.",
+	}
+	for _, d := range downloads {
+		records.Add(d.FeedName, d.PostTitle, d.Path, d.Size, d.Sha256, d.CreatedAt)
+	}
+	return s.Emit(records)
+}
+
+func downloadDir(s *State) (string, error) {
+	if s.Cfg.DownloadDir != "" {
+		return s.Cfg.DownloadDir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("no download_dir configured and no home directory: %v", err)
+	}
+	return filepath.Join(home, "gator-downloads"), nil
+}
+
+// episodePath is where an enclosure is saved. Episodes often share a title
+// ("Bonus", "Trailer"), so the start of the enclosure ID keeps them apart.
+func episodePath(dir string, t episodeTarget) string {
+	name := fmt.Sprintf("%s [%s]%s", safeFileName(t.PostTitle), t.EnclosureID.String()[:8], episodeExt(t))
+	return filepath.Join(dir, safeFileName(t.FeedName), name)
+}
+
+// episodeExt picks a file extension from the enclosure URL, falling back to
+// its MIME type.
+func episodeExt(t episodeTarget) string {
+	if u, err := url.Parse(t.URL); err == nil {
+		if ext := path.Ext(u.Path); ext != "" && len(ext) <= 5 {
+			return ext
+		}
+	}
+	if exts, err := mime.ExtensionsByType(t.Type); err == nil && len(exts) > 0 {
+		return exts[0]
+	}
+	return ""
+}
+
+// safeFileName turns a title into something every filesystem accepts.
+func safeFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r < 0x20, strings.ContainsRune(`/\:*?"<>|`, r):
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(name))
+	name = strings.Trim(name, ". ")
+	if len([]rune(name)) > 100 {
+		name = string([]rune(name)[:100])
+	}
+	if name == "" {
+		return "untitled"
+	}
+	return name
+}
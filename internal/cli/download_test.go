@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/JadedPigeon/Gator/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestEpisodePath(t *testing.T) {
+	a := episodeTarget{
+		EnclosureID: uuid.MustParse("3f2a9c1e-0000-4000-8000-000000000001"),
+		URL:         "https://cdn.example.com/ep/bonus.mp3?token=x",
+		PostTitle:   "Bonus: Q&A?",
+		FeedName:    "My/Show",
+	}
+	want := filepath.Join("dl", "My_Show", "Bonus_ Q&A_ [3f2a9c1e].mp3")
+	if got := episodePath("dl", a); got != want {
+		t.Errorf("episodePath = %q, want %q", got, want)
+	}
+
+	// Same title, different enclosure
+	b := a
+	b.EnclosureID = uuid.MustParse("7d41b0aa-0000-4000-8000-000000000002")
+	if episodePath("dl", a) == episodePath("dl", b) {
+		t.Error("two episodes with the same title got the same path")
+	}
+}
+
+func TestEpisodeExtFromType(t *testing.T) {
+	got := episodeExt(episodeTarget{URL: "https://example.com/stream", Type: "audio/mpeg"})
+	if got == "" {
+		t.Error("no extension for audio/mpeg")
+	}
+}
+
+// parseCommand parses args the way Commands.Run does for the named command.
+func parseCommand(t *testing.T, name string, args ...string) Command {
+	t.Helper()
+	fs := NewCommands().Info[name].flagSet(name)
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Command{Name: name, Args: positional, Flags: fs}
+}
+
+func TestDownloadTargetsRequireFollow(t *testing.T) {
+	s, feeds := aggState(t, 1, nil)
+	ctx := context.Background()
+	user, err := s.DB.GetUser(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	posts, err := s.DB.CreatePosts(ctx, database.CreatePostsParams{
+		FeedID:       feeds[0].ID,
+		Titles:       []string{"Episode 1"},
+		Urls:         []string{"https://example.com/episodes/1"},
+		Descriptions: []string{""},
+		PublishedAts: []string{""},
+		Authors:      []string{""},
+		CommentsUrls: []string{""},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.DB.CreatePostEnclosures(ctx, database.CreatePostEnclosuresParams{
+		PostIds: []uuid.UUID{posts[0].ID},
+		Urls:    []string{"https://example.com/episodes/1.mp3"},
+		Types:   []string{"audio/mpeg"},
+		Lengths: []int64{0},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmds := []struct {
+		cmd  Command
+		want string
+	}{
+		{parseCommand(t, "download", posts[0].ID.String()), "does not exist"},
+		{parseCommand(t, "download", posts[0].Url), "does not exist"},
+		{parseCommand(t, "download", "--feed", feeds[0].Url), "not following"},
+	}
+	for _, tt := range cmds {
+		_, err := downloadTargets(ctx, s, tt.cmd, user)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("download %v without following: got %v, want an error containing %q", tt.cmd.Args, err, tt.want)
+		}
+	}
+
+	if _, err := s.DB.CreateFeedFollow(ctx, database.CreateFeedFollowParams{FeedID: feeds[0].ID, UserID: user.ID}); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range cmds {
+		targets, err := downloadTargets(ctx, s, tt.cmd, user)
+		if err != nil {
+			t.Fatalf("download %v: %v", tt.cmd.Args, err)
+		}
+		if len(targets) != 1 || targets[0].URL != "https://example.com/episodes/1.mp3" {
+			t.Errorf("download %v: got %+v, want the episode's enclosure", tt.cmd.Args, targets)
+		}
+	}
+}
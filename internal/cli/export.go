@@ -0,0 +1,26 @@
+package cli
+
+import (
+	"context"
+	"errors"
+
+	"github.com/JadedPigeon/Gator/internal/database"
+	"github.com/JadedPigeon/Gator/internal/export"
+)
+
+func HandlerExportFeed(ctx context.Context, s *State, cmd Command, user database.User) error {
+	format, err := export.ParseFormat(cmd.FlagString("format"))
+	if err != nil {
+		return err
+	}
+	limit := cmd.FlagInt("limit")
+	if limit <= 0 {
+		return errors.New("limit must be a positive integer")
+	}
+	return export.Write(ctx, s.stdout(), s.DB, user, export.Options{
+		Format: format,
+		Tag:    cmd.FlagString("tag"),
+		Limit:  limit,
+		Link:   cmd.FlagString("link"),
+	})
+}
@@ -0,0 +1,226 @@
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/JadedPigeon/Gator/internal/database"
+	"github.com/JadedPigeon/Gator/internal/filter"
+	"github.com/google/uuid"
+)
+
+func HandlerFilterAdd(ctx context.Context, s *State, cmd Command, user database.User) error {
+	rule := filter.Rule{
+		UserID:  user.ID,
+		Field:   cmd.Args[0],
+		Match:   cmd.Args[1],
+		Pattern: cmd.Args[2],
+		Action:  cmd.Args[3],
+	}
+	if feedURL := cmd.FlagString("feed"); feedURL != "" {
+		feed, err := s.DB.GetFeedByUrl(ctx, feedURL)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("feed with URL %s does not exist", feedURL)
+			}
+			return fmt.Errorf("error checking feed: %v", err)
+		}
+		following, err := s.DB.IsFollowingFeed(ctx, database.IsFollowingFeedParams{
+			FeedID: feed.ID,
+			UserID: user.ID,
+		})
+		if err != nil {
+			return fmt.Errorf("error checking follows: %v", err)
+		}
+		if !following {
+			return fmt.Errorf("you are not following feed %s", feedURL)
+		}
+		rule.FeedID = uuid.NullUUID{UUID: feed.ID, Valid: true}
+	}
+	if _, err := filter.Compile(rule); err != nil {
+		return err
+	}
+
+	created, err := s.DB.CreateFilterRule(ctx, database.CreateFilterRuleParams{
+		UserID:    rule.UserID,
+		FeedID:    rule.FeedID,
+		Field:     rule.Field,
+		MatchType: rule.Match,
+		Pattern:   rule.Pattern,
+		Action:    rule.Action,
+	})
+	if err != nil {
+		return fmt.Errorf("error creating filter rule: %v", err)
+	}
+	fmt.Printf("Filter rule %s added\n", created.ID)
+	return nil
+}
+
+func HandlerFilters(ctx context.Context, s *State, cmd Command, user database.User) error {
+	rules, err := s.DB.GetFilterRulesForUser(ctx, user.ID)
+	if err != nil {
+		return fmt.Errorf("error retrieving filter rules: %v", err)
+	}
+	records := Records{
+		Columns: []string{"id", "field", "match", "pattern", "action", "feed_url"},
+		Empty:   "No filter rules found.",
+	}
+	for _, rule := range rules {
+		records.Add(rule.ID, rule.Field, rule.MatchType, rule.Pattern, rule.Action, rule.FeedUrl)
+	}
+	return s.Emit(records)
+}
+
+func HandlerFilterTest(ctx context.Context, s *State, cmd Command, user database.User) error {
+	ruleID, err := uuid.Parse(cmd.Args[0])
+	if err != nil {
+		return fmt.Errorf("invalid rule ID %q", cmd.Args[0])
+	}
+	limit := cmd.FlagInt("limit")
+	if limit <= 0 {
+		return errors.New("limit must be a positive integer")
+	}
+
+	row, err := s.DB.GetFilterRule(ctx, database.GetFilterRuleParams{
+		ID:     ruleID,
+		UserID: user.ID,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("filter rule %s does not exist", ruleID)
+		}
+		return fmt.Errorf("error retrieving filter rule: %v", err)
+	}
+	rule, err := filter.Compile(ruleFromDB(row))
+	if err != nil {
+		return err
+	}
+
+	posts, err := s.DB.GetPostsForUser(ctx, database.GetPostsForUserParams{
+		UserID:   user.ID,
+		RowLimit: int32(limit),
+	})
+	if err != nil {
+		return fmt.Errorf("error retrieving posts: %v", err)
+	}
+	records := Records{
+		Columns: []string{"id", "feed", "title", "url", "published_at"},
+		Empty:   fmt.Sprintf("Rule matches none of the %d most recent posts.", len(posts)),
+	}
+	for _, post := range posts {
+		if rule.Matches(filterItem(post)) {
+			records.Add(post.ID, post.FeedName, post.Title, post.Url, post.PublishedAt)
+		}
+	}
+	if err := s.Emit(records); err != nil {
+		return err
+	}
+	// The summary would break structured output
+	if s.Format == OutputTable && len(records.Rows) > 0 {
+		fmt.Fprintf(s.stdout(), "Rule would %s %d of the %d most recent posts\n", rule.Action, len(records.Rows), len(posts))
+	}
+	return nil
+}
+
+func HandlerFilterDelete(ctx context.Context, s *State, cmd Command, user database.User) error {
+	ruleID, err := uuid.Parse(cmd.Args[0])
+	if err != nil {
+		return fmt.Errorf("invalid rule ID %q", cmd.Args[0])
+	}
+	deleted, err := s.DB.DeleteFilterRule(ctx, database.DeleteFilterRuleParams{
+		ID:     ruleID,
+		UserID: user.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("error deleting filter rule: %v", err)
+	}
+	if deleted == 0 {
+		return fmt.Errorf("filter rule %s does not exist", ruleID)
+	}
+	fmt.Printf("Filter rule %s deleted\n", ruleID)
+	return nil
+}
+
+func ruleFromDB(r database.FilterRule) filter.Rule {
+	return filter.Rule{
+		ID:      r.ID,
+		UserID:  r.UserID,
+		FeedID:  r.FeedID,
+		Field:   r.Field,
+		Match:   r.MatchType,
+		Pattern: r.Pattern,
+		Action:  r.Action,
+	}
+}
+
+// loadUserRules returns the compiled rules of a user. Rules that no longer
+// compile are skipped rather than blocking browse.
+func loadUserRules(ctx context.Context, s *State, userID uuid.UUID) ([]filter.Rule, error) {
+	rows, err := s.DB.GetFilterRulesForUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving filter rules: %v", err)
+	}
+	var rules []filter.Rule
+	for _, row := range rows {
+		rule, err := filter.Compile(ruleFromDB(database.FilterRule{
+			ID:        row.ID,
+			UserID:    row.UserID,
+			FeedID:    row.FeedID,
+			Field:     row.Field,
+			MatchType: row.MatchType,
+			Pattern:   row.Pattern,
+			Action:    row.Action,
+		}))
+		if err != nil {
+			s.Log.Warn("Skipping filter rule", "rule_id", row.ID, "error", err)
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// loadFeedRules returns the compiled rules of every user following a feed,
+// grouped by user.
+func loadFeedRules(ctx context.Context, s *State, feedID uuid.UUID) (map[uuid.UUID][]filter.Rule, error) {
+	rows, err := s.DB.GetFilterRulesForFeed(ctx, feedID)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving filter rules: %v", err)
+	}
+	rules := make(map[uuid.UUID][]filter.Rule)
+	for _, row := range rows {
+		rule, err := filter.Compile(ruleFromDB(row))
+		if err != nil {
+			s.Log.Warn("Skipping filter rule", "rule_id", row.ID, "error", err)
+			continue
+		}
+		rules[row.UserID] = append(rules[row.UserID], rule)
+	}
+	return rules, nil
+}
+
+func filterItem(post database.GetPostsForUserRow) filter.Item {
+	return filter.Item{
+		FeedID:      post.FeedID,
+		Title:       post.Title,
+		Description: post.Description.String,
+		URL:         post.Url,
+		Author:      post.Author.String,
+	}
+}
+
+func savePostState(ctx context.Context, q *database.Queries, userID, postID uuid.UUID, res filter.Result) error {
+	_, err := q.UpsertPostState(ctx, database.UpsertPostStateParams{
+		UserID:  userID,
+		PostID:  postID,
+		Read:    res.MarkRead,
+		Starred: res.Star,
+		Hidden:  res.Hide,
+	})
+	if err != nil {
+		return fmt.Errorf("error saving post state: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package cli
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/JadedPigeon/Gator/internal/database"
+)
+
+func TestFilterAddRequiresFollow(t *testing.T) {
+	s, feeds := aggState(t, 1, nil)
+	s.Cfg.CurrentUser = "alice"
+	ctx := context.Background()
+	cmd := Command{Name: "filter-add", Args: []string{"--feed", feeds[0].Url, "title", "substring", "ad", "hide"}}
+
+	err := NewCommands().Run(ctx, s, cmd)
+	if err == nil || !strings.Contains(err.Error(), "not following") {
+		t.Fatalf("got %v, want an error about not following the feed", err)
+	}
+
+	user, err := s.DB.GetUser(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.DB.CreateFeedFollow(ctx, database.CreateFeedFollowParams{FeedID: feeds[0].ID, UserID: user.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewCommands().Run(ctx, s, cmd); err != nil {
+		t.Fatalf("filter-add on a followed feed: %v", err)
+	}
+}
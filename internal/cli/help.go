@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+func (c *Commands) HandlerHelp(ctx context.Context, s *State, cmd Command) error {
+	if len(cmd.Args) == 1 {
+		return c.printCommandHelp(s.stdout(), cmd.Args[0])
+	}
+	return c.PrintHelp(s.stdout())
+}
+
+// PrintHelp writes the list of commands.
+func (c *Commands) PrintHelp(w io.Writer) error {
+	fmt.Fprintln(w, "Usage: gator [global flags] <command> [flags] [args]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, name := range c.names() {
+		fmt.Fprintf(tw, "  %s\t%s\n", name, c.Info[name].Description)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if c.Global != nil {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Global flags:")
+		printFlags(w, c.Global)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'gator help <command>' for details about a command.")
+	return nil
+}
+
+func (c *Commands) printCommandHelp(w io.Writer, name string) error {
+	info, ok := c.Info[name]
+	if !ok {
+		return fmt.Errorf("unknown command: %s (run 'gator help' to list commands)", name)
+	}
+	fmt.Fprintf(w, "Usage: %s\n", synopsis(name, info))
+	if info.Description != "" {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, info.Description)
+	}
+	if fs := info.flagSet(name); hasFlags(fs) {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Flags:")
+		printFlags(w, fs)
+	}
+	return nil
+}
+
+func (c *Commands) names() []string {
+	names := make([]string, 0, len(c.Handlers))
+	for name := range c.Handlers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func synopsis(name string, info CommandInfo) string {
+	parts := []string{"gator", name}
+	if hasFlags(info.flagSet(name)) {
+		parts = append(parts, "[flags]")
+	}
+	if info.Usage != "" {
+		parts = append(parts, info.Usage)
+	}
+	return strings.Join(parts, " ")
+}
+
+func hasFlags(fs *flag.FlagSet) bool {
+	found := false
+	fs.VisitAll(func(*flag.Flag) { found = true })
+	return found
+}
+
+func printFlags(w io.Writer, fs *flag.FlagSet) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fs.VisitAll(func(f *flag.Flag) {
+		name := "--" + f.Name
+		if kind, _ := flag.UnquoteUsage(f); kind != "" {
+			name += " " + kind
+		}
+		usage := f.Usage
+		if f.DefValue != "" && f.DefValue != "false" && f.DefValue != "0" {
+			usage += fmt.Sprintf(" (default %s)", f.DefValue)
+		}
+		fmt.Fprintf(tw, "  %s\t%s\n", name, usage)
+	})
+	tw.Flush()
+}
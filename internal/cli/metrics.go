@@ -0,0 +1,152 @@
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/JadedPigeon/Gator/internal/database"
+	"github.com/JadedPigeon/Gator/internal/metrics"
+	"github.com/JadedPigeon/Gator/internal/rss"
+)
+
+// aggMetrics are the metrics agg serves with --metrics-addr. A nil
+// *aggMetrics records nothing, so callers don't need to check.
+type aggMetrics struct {
+	reg           *metrics.Registry
+	fetches       *metrics.Counter
+	fetchSeconds  *metrics.Histogram
+	fetchBytes    *metrics.Counter
+	parseFailures *metrics.Counter
+	postsInserted *metrics.Counter
+	postsSkipped  *metrics.Counter
+	dbErrors      *metrics.Counter
+}
+
+// newAggMetrics sets up agg's metrics. interval is how often agg fetches,
+// which the queue lag is measured against.
+func newAggMetrics(s *State, interval time.Duration) *aggMetrics {
+	reg := metrics.NewRegistry()
+	m := &aggMetrics{
+		reg: reg,
+		fetches: reg.Counter("gator_feed_fetches_total",
+			`Feed fetches by HTTP status, or "error" if no response arrived.`, "status"),
+		fetchSeconds: reg.Histogram("gator_feed_fetch_duration_seconds",
+			"How long feed fetches took, including parsing and storing posts.",
+			[]float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}),
+		fetchBytes: reg.Counter("gator_feed_fetch_bytes_total",
+			"Bytes of feed downloaded, before decompression."),
+		parseFailures: reg.Counter("gator_feed_parse_failures_total",
+			"Fetches that returned a document that isn't valid XML."),
+		postsInserted: reg.Counter("gator_posts_inserted_total",
+			"New posts stored, from fetches and WebSub pushes."),
+		postsSkipped: reg.Counter("gator_posts_deduplicated_total",
+			"Posts skipped because their URL was already stored."),
+		dbErrors: reg.Counter("gator_db_errors_total",
+			"Database queries that failed."),
+	}
+	reg.GaugeFunc("gator_feed_queue_lag_seconds",
+		"How far past the fetch interval the feed next in line has waited since it was last tried.",
+		func(ctx context.Context) (float64, error) {
+			return s.DB.GetFeedQueueLag(ctx, interval.Seconds())
+		})
+	return m
+}
+
+// fetched is the Fetcher's OnFetch hook.
+func (m *aggMetrics) fetched(info rss.FetchInfo) {
+	if m == nil {
+		return
+	}
+	status := "error"
+	if info.Status != 0 {
+		status = strconv.Itoa(info.Status)
+	}
+	m.fetches.Inc(status)
+	m.fetchSeconds.Observe(info.Duration.Seconds())
+	m.fetchBytes.Add(float64(info.Bytes))
+	if info.ParseFailed() {
+		m.parseFailures.Inc()
+	}
+}
+
+// stored records the posts of a committed fetch or push.
+func (m *aggMetrics) stored(stats ingestStats) {
+	if m == nil {
+		return
+	}
+	m.postsInserted.Add(float64(stats.Inserted))
+	m.postsSkipped.Add(float64(stats.Skipped))
+}
+
+// serve starts the metrics server on addr. Listening happens up front, so a
+// port that's taken stops agg from starting.
+func (m *aggMetrics) serve(s *State, addr string) (*http.Server, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("error listening for metrics: %v", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", m.reg.Handler())
+	srv := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.Log.Error("Metrics server failed", "error", err)
+		}
+	}()
+	s.Log.Info("Serving metrics", "addr", "http://"+ln.Addr().String()+"/metrics")
+	return srv, nil
+}
+
+// db wraps a connection or transaction so failed queries are counted.
+func (m *aggMetrics) db(db database.DBTX) database.DBTX {
+	if m == nil {
+		return db
+	}
+	return &countingDB{DBTX: db, errors: m.dbErrors}
+}
+
+type countingDB struct {
+	database.DBTX
+	errors *metrics.Counter
+}
+
+func (c *countingDB) count(err error) {
+	// Interrupted queries aren't the database's fault
+	if err != nil && !errors.Is(err, context.Canceled) {
+		c.errors.Inc()
+	}
+}
+
+func (c *countingDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	res, err := c.DBTX.ExecContext(ctx, query, args...)
+	c.count(err)
+	return res, err
+}
+
+func (c *countingDB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	stmt, err := c.DBTX.PrepareContext(ctx, query)
+	c.count(err)
+	return stmt, err
+}
+
+func (c *countingDB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	rows, err := c.DBTX.QueryContext(ctx, query, args...)
+	c.count(err)
+	return rows, err
+}
+
+func (c *countingDB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	row := c.DBTX.QueryRowContext(ctx, query, args...)
+	// Err doesn't report sql.ErrNoRows, which is no failure anyway
+	c.count(row.Err())
+	return row
+}
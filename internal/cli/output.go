@@ -0,0 +1,215 @@
+package cli
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type OutputFormat string
+
+const (
+	OutputTable OutputFormat = "table"
+	OutputJSON  OutputFormat = "json"
+	OutputJSONL OutputFormat = "jsonl"
+	OutputCSV   OutputFormat = "csv"
+)
+
+func ParseOutputFormat(s string) (OutputFormat, error) {
+	switch f := OutputFormat(strings.ToLower(s)); f {
+	case OutputTable, OutputJSON, OutputJSONL, OutputCSV:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown output format %q (expected table, json, jsonl or csv)", s)
+}
+
+// Records is what list commands hand to the output layer instead of printing
+// themselves. Every row has one value per column, in column order.
+type Records struct {
+	Columns []string
+	Rows    [][]any
+	// Empty is printed instead of an empty table; structured formats print
+	// an empty document instead.
+	Empty string
+}
+
+func (r *Records) Add(values ...any) {
+	r.Rows = append(r.Rows, values)
+}
+
+func (s *State) stdout() io.Writer {
+	if s.Out != nil {
+		return s.Out
+	}
+	return os.Stdout
+}
+
+// Emit writes records in the format selected with --output.
+func (s *State) Emit(r Records) error {
+	w := s.stdout()
+	switch s.Format {
+	case OutputJSON:
+		objects := make([]orderedObject, 0, len(r.Rows))
+		for _, row := range r.Rows {
+			objects = append(objects, orderedObject{keys: r.Columns, values: row})
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(objects)
+	case OutputJSONL:
+		enc := json.NewEncoder(w)
+		for _, row := range r.Rows {
+			if err := enc.Encode(orderedObject{keys: r.Columns, values: row}); err != nil {
+				return err
+			}
+		}
+		return nil
+	case OutputCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(r.Columns); err != nil {
+			return err
+		}
+		for _, row := range r.Rows {
+			record := make([]string, len(row))
+			for i, v := range row {
+				record[i] = formatValue(v)
+			}
+			if err := cw.Write(record); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	default:
+		if len(r.Rows) == 0 {
+			_, err := fmt.Fprintln(w, r.Empty)
+			return err
+		}
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.ToUpper(strings.Join(r.Columns, "\t")))
+		for _, row := range r.Rows {
+			cells := make([]string, len(row))
+			for i, v := range row {
+				cells[i] = formatTableValue(v)
+			}
+			fmt.Fprintln(tw, strings.Join(cells, "\t"))
+		}
+		return tw.Flush()
+	}
+}
+
+// orderedObject marshals to a JSON object that keeps the column order.
+type orderedObject struct {
+	keys   []string
+	values []any
+}
+
+func (o orderedObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		var v any
+		if i < len(o.values) {
+			v = jsonValue(o.values[i])
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(b)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// jsonValue unwraps the nullable database types so they encode as plain
+// values or null.
+func jsonValue(v any) any {
+	switch v := v.(type) {
+	case sql.NullString:
+		if !v.Valid {
+			return nil
+		}
+		return v.String
+	case sql.NullTime:
+		if !v.Valid {
+			return nil
+		}
+		return v.Time.UTC().Format(time.RFC3339)
+	case sql.NullInt64:
+		if !v.Valid {
+			return nil
+		}
+		return v.Int64
+	case sql.NullInt32:
+		if !v.Valid {
+			return nil
+		}
+		return v.Int32
+	case sql.NullBool:
+		if !v.Valid {
+			return nil
+		}
+		return v.Bool
+	case uuid.NullUUID:
+		if !v.Valid {
+			return nil
+		}
+		return v.UUID.String()
+	case time.Time:
+		return v.UTC().Format(time.RFC3339)
+	}
+	return v
+}
+
+func formatValue(v any) string {
+	switch v := jsonValue(v).(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func formatTableValue(v any) string {
+	switch v := v.(type) {
+	case bool:
+		if v {
+			return "yes"
+		}
+		return ""
+	case sql.NullBool:
+		if !v.Valid {
+			return ""
+		}
+		return formatTableValue(v.Bool)
+	case time.Time:
+		return v.Local().Format("2006-01-02 15:04")
+	case sql.NullTime:
+		if !v.Valid {
+			return ""
+		}
+		return v.Time.Local().Format("2006-01-02 15:04")
+	}
+	return formatValue(v)
+}
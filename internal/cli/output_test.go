@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"bytes"
+	"database/sql"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func sampleRecords() Records {
+	published := time.Date(2024, 3, 9, 14, 30, 0, 0, time.UTC)
+	r := Records{
+		Columns: []string{"id", "title", "author", "published_at", "fetched_at", "read", "feed_id"},
+		Empty:   "Nothing to show.",
+	}
+	r.Add(
+		uuid.MustParse("6f1c2a7e-0d3b-4a7c-9a51-2c1e7f0b9d42"),
+		`Quotes "and", commas`,
+		sql.NullString{String: "Ada", Valid: true},
+		sql.NullTime{Time: published, Valid: true},
+		published.Add(90*time.Minute),
+		true,
+		uuid.NullUUID{UUID: uuid.MustParse("0b7e1d2c-5f4a-4e3b-8c9d-1a2b3c4d5e6f"), Valid: true},
+	)
+	// NULLs come out empty in tables and CSV and as null in JSON
+	r.Add(
+		uuid.MustParse("9a8b7c6d-5e4f-4a3b-8c2d-1e0f9a8b7c6d"),
+		"Second post",
+		sql.NullString{},
+		sql.NullTime{},
+		published,
+		false,
+		uuid.NullUUID{},
+	)
+	return r
+}
+
+func TestEmitGolden(t *testing.T) {
+	// The table format shows times in the local zone
+	local := time.Local
+	time.Local = time.UTC
+	t.Cleanup(func() { time.Local = local })
+
+	tests := []struct {
+		name   string
+		format OutputFormat
+		empty  bool
+	}{
+		{"table", OutputTable, false},
+		{"json", OutputJSON, false},
+		{"jsonl", OutputJSONL, false},
+		{"csv", OutputCSV, false},
+		{"table_empty", OutputTable, true},
+		{"json_empty", OutputJSON, true},
+		{"jsonl_empty", OutputJSONL, true},
+		{"csv_empty", OutputCSV, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records := sampleRecords()
+			if tt.empty {
+				records.Rows = nil
+			}
+			var out bytes.Buffer
+			s := &State{Format: tt.format, Out: &out}
+			if err := s.Emit(records); err != nil {
+				t.Fatalf("Emit: %v", err)
+			}
+
+			golden := filepath.Join("testdata", "emit_"+tt.name+".golden")
+			if *update {
+				if err := os.WriteFile(golden, out.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("reading golden file (run with -update to create it): %v", err)
+			}
+			if !bytes.Equal(out.Bytes(), want) {
+				t.Errorf("output differs from %s\ngot:\n%s\nwant:\n%s", golden, out.Bytes(), want)
+			}
+		})
+	}
+}
+
+func TestParseOutputFormat(t *testing.T) {
+	for _, s := range []string{"table", "JSON", "jsonl", "csv"} {
+		if _, err := ParseOutputFormat(s); err != nil {
+			t.Errorf("ParseOutputFormat(%q): %v", s, err)
+		}
+	}
+	if _, err := ParseOutputFormat("yaml"); err == nil {
+		t.Error("ParseOutputFormat(yaml) succeeded, want an error")
+	}
+}
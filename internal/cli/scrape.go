@@ -0,0 +1,381 @@
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/JadedPigeon/Gator/internal/database"
+	"github.com/JadedPigeon/Gator/internal/filter"
+	"github.com/JadedPigeon/Gator/internal/rss"
+	"github.com/google/uuid"
+)
+
+// postBatchSize is how many items go into one multi-row insert.
+const postBatchSize = 100
+
+// feedLeaseMargin is how much longer than the fetch timeout a feed is
+// leased for at a time. The lease is renewed while the posts are stored.
+const feedLeaseMargin = time.Minute
+
+// ingestStats counts what happened to the items of one fetch.
+type ingestStats struct {
+	Inserted int
+	Skipped  int // already stored
+	Failed   int // unusable, e.g. no link
+	// Alerts are run once the posts are committed; see runAlerts
+	Alerts []pendingAlert
+}
+
+// Items is how many items the feed had.
+func (st ingestStats) Items() int {
+	return st.Inserted + st.Skipped + st.Failed
+}
+
+// scrapeFeeds fetches the feed that has waited longest. The feed is leased
+// to owner while it is fetched, so other aggregators leave it alone.
+func scrapeFeeds(ctx context.Context, s *State, owner string) error {
+	// Moving the feed to the back of the queue as it is claimed means a
+	// feed that keeps failing doesn't starve the others
+	lease := s.Cfg.FetchTimeout.Or(defaultFetchTimeout) + feedLeaseMargin
+	nextfeed, err := s.DB.ClaimNextFeed(ctx, database.ClaimNextFeedParams{
+		Owner:        owner,
+		LeaseSeconds: int32(lease.Seconds()),
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			s.Log.Debug("No feeds to fetch")
+			return nil
+		}
+		return fmt.Errorf("error claiming next feed to fetch: %v", err)
+	}
+	log := s.Log.With("feed_id", nextfeed.ID, "feed_url", nextfeed.Url)
+	defer func() {
+		// Released even when interrupted, so the feed isn't stuck until
+		// the lease runs out
+		err := s.DB.ReleaseFeedLease(context.WithoutCancel(ctx), database.ReleaseFeedLeaseParams{
+			ID:    nextfeed.ID,
+			Owner: owner,
+		})
+		if err != nil {
+			log.Warn("Releasing feed lease failed", "error", err)
+		}
+	}()
+	// Storing a large feed can take longer than the lease
+	defer keepFeedLease(ctx, s, log, nextfeed.ID, owner, lease)()
+	fetcher, err := s.feedFetcher()
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	var channel *rss.RSSChannel
+	stats, err := ingestFeed(ctx, s, nextfeed.ID, func(fn func(rss.RSSItem) error) error {
+		fetchCtx, cancel := context.WithTimeout(ctx, s.Cfg.FetchTimeout.Or(defaultFetchTimeout))
+		defer cancel()
+		channel, err = fetcher.FetchStream(fetchCtx, nextfeed.Url, fn)
+		return err
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		attrs := []any{"duration", time.Since(start), "items", stats.Items(), "error", err}
+		var httpErr *rss.HTTPError
+		if errors.As(err, &httpErr) {
+			attrs = append(attrs, "status", httpErr.StatusCode)
+		}
+		log.Error("Fetching feed failed", attrs...)
+		return nil
+	}
+	log.Info("Fetched feed", "feed", nextfeed.Name, "status", http.StatusOK, "duration", time.Since(start),
+		"items", stats.Items(), "inserted", stats.Inserted, "skipped", stats.Skipped, "failed", stats.Failed)
+	runAlerts(ctx, s, stats.Alerts)
+	if err := subscribeWebSub(ctx, s, nextfeed, channel); err != nil {
+		log.Warn("WebSub subscription failed", "error", err)
+	}
+	return nil
+}
+
+// keepFeedLease renews the lease on a feed every third of its length, until
+// the returned function is called.
+func keepFeedLease(ctx context.Context, s *State, log *slog.Logger, feedID uuid.UUID, owner string, lease time.Duration) (stop func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(lease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			n, err := s.DB.RenewFeedLease(ctx, database.RenewFeedLeaseParams{
+				LeaseSeconds: int32(lease.Seconds()),
+				ID:           feedID,
+				Owner:        owner,
+			})
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Warn("Renewing feed lease failed", "error", err)
+				}
+				continue
+			}
+			if n == 0 {
+				log.Warn("Feed lease was lost, another aggregator may fetch the feed as well")
+				return
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// ingestFeed stores the items produced by stream in a single transaction and
+// marks the feed as fetched only if that transaction commits. stream is
+// called once with the function that accepts each item. The transaction is
+// only begun once a full batch has been parsed, or the feed has ended, so a
+// slow or unresponsive server never holds one open.
+func ingestFeed(ctx context.Context, s *State, feedID uuid.UUID, stream func(func(rss.RSSItem) error) error) (ingestStats, error) {
+	rules, err := loadFeedRules(ctx, s, feedID)
+	if err != nil {
+		return ingestStats{}, err
+	}
+
+	b := &postBatcher{
+		s:      s,
+		feedID: feedID,
+		rules:  rules,
+	}
+	defer b.rollback()
+	if err := stream(func(item rss.RSSItem) error {
+		return b.add(ctx, item)
+	}); err != nil {
+		return b.stats, err
+	}
+	if err := b.flush(ctx); err != nil {
+		return b.stats, err
+	}
+	// A feed with nothing new still needs the transaction for this
+	if err := b.begin(ctx); err != nil {
+		return b.stats, err
+	}
+	// Recorded in the same transaction, so it only sticks if the posts do
+	if err := b.q.MarkFeedFetched(ctx, feedID); err != nil {
+		return b.stats, fmt.Errorf("error marking feed as fetched: %v", err)
+	}
+	if err := b.tx.Commit(); err != nil {
+		return b.stats, fmt.Errorf("error committing posts: %v", err)
+	}
+	s.Metrics.stored(b.stats)
+	return b.stats, nil
+}
+
+// postBatcher buffers parsed items and inserts them a batch at a time, in a
+// transaction it begins with the first insert.
+type postBatcher struct {
+	s       *State
+	tx      *sql.Tx
+	q       *database.Queries
+	feedID  uuid.UUID
+	rules   map[uuid.UUID][]filter.Rule
+	pending []rss.RSSItem
+	stats   ingestStats
+}
+
+// begin starts the batcher's transaction unless it is already open.
+func (b *postBatcher) begin(ctx context.Context) error {
+	if b.tx != nil {
+		return nil
+	}
+	tx, err := b.s.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	b.tx = tx
+	b.q = b.s.withTx(tx)
+	return nil
+}
+
+// rollback undoes the transaction if one was begun and not committed.
+func (b *postBatcher) rollback() {
+	if b.tx != nil {
+		b.tx.Rollback()
+	}
+}
+
+func (b *postBatcher) add(ctx context.Context, item rss.RSSItem) error {
+	if item.Link == "" {
+		b.stats.Failed++
+		return nil
+	}
+	b.pending = append(b.pending, item)
+	if len(b.pending) >= postBatchSize {
+		return b.flush(ctx)
+	}
+	return nil
+}
+
+func (b *postBatcher) flush(ctx context.Context) error {
+	if len(b.pending) == 0 {
+		return nil
+	}
+	if err := b.begin(ctx); err != nil {
+		return err
+	}
+	params := database.CreatePostsParams{FeedID: b.feedID}
+	for _, item := range b.pending {
+		published := ""
+		if t, ok := parsePubDate(item.PubDate); ok {
+			published = t.UTC().Format("2006-01-02 15:04:05")
+		}
+		params.Titles = append(params.Titles, item.Title)
+		params.Urls = append(params.Urls, item.Link)
+		params.Descriptions = append(params.Descriptions, item.Description)
+		params.PublishedAts = append(params.PublishedAts, published)
+		params.Authors = append(params.Authors, item.Author)
+		params.CommentsUrls = append(params.CommentsUrls, item.Comments)
+	}
+
+	// Posts whose URL is already stored are skipped by the insert itself
+	posts, err := b.q.CreatePosts(ctx, params)
+	if err != nil {
+		b.stats.Failed += len(b.pending)
+		return fmt.Errorf("error inserting posts: %v", err)
+	}
+	b.stats.Inserted += len(posts)
+	b.stats.Skipped += len(b.pending) - len(posts)
+	if err := b.saveMetadata(ctx, posts); err != nil {
+		return err
+	}
+	b.pending = b.pending[:0]
+
+	// Apply each follower's filter rules to the new posts
+	for _, post := range posts {
+		fi := filter.Item{
+			FeedID:      post.FeedID,
+			Title:       post.Title,
+			Description: post.Description.String,
+			URL:         post.Url,
+			Author:      post.Author.String,
+		}
+		for userID, userRules := range b.rules {
+			res := filter.Apply(userRules, fi)
+			if res.Alert && !res.Hide {
+				b.stats.Alerts = append(b.stats.Alerts, pendingAlert{UserID: userID, PostID: post.ID})
+			}
+			if !res.Any() {
+				continue
+			}
+			if err := savePostState(ctx, b.q, userID, post.ID, res); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Queued last, so webhooks see the categories and hidden flags above;
+	// agg sends them once the transaction commits
+	if len(posts) > 0 {
+		ids := make([]uuid.UUID, len(posts))
+		for i, post := range posts {
+			ids[i] = post.ID
+		}
+		if _, err := b.q.EnqueueWebhookDeliveries(ctx, ids); err != nil {
+			return fmt.Errorf("error queueing webhooks: %v", err)
+		}
+	}
+	return nil
+}
+
+// saveMetadata stores the categories, enclosures, thumbnails and podcast
+// details of newly inserted posts.
+func (b *postBatcher) saveMetadata(ctx context.Context, posts []database.Post) error {
+	items := make(map[string]rss.RSSItem, len(b.pending))
+	for _, item := range b.pending {
+		items[item.Link] = item
+	}
+	var categories database.CreatePostCategoriesParams
+	var enclosures database.CreatePostEnclosuresParams
+	var episodes database.CreatePodcastEpisodesParams
+	var thumbnails database.CreatePostThumbnailsParams
+	for _, post := range posts {
+		item := items[post.Url]
+		for _, name := range item.Categories {
+			if name = strings.TrimSpace(name); name != "" {
+				categories.PostIds = append(categories.PostIds, post.ID)
+				categories.Names = append(categories.Names, name)
+			}
+		}
+		for _, e := range item.Enclosures {
+			if e.URL == "" {
+				continue
+			}
+			enclosures.PostIds = append(enclosures.PostIds, post.ID)
+			enclosures.Urls = append(enclosures.Urls, e.URL)
+			enclosures.Types = append(enclosures.Types, e.Type)
+			enclosures.Lengths = append(enclosures.Lengths, e.Size())
+		}
+		for _, t := range item.Thumbnails() {
+			thumbnails.PostIds = append(thumbnails.PostIds, post.ID)
+			thumbnails.Urls = append(thumbnails.Urls, t.URL)
+			thumbnails.Widths = append(thumbnails.Widths, t.Width)
+			thumbnails.Heights = append(thumbnails.Heights, t.Height)
+		}
+		if item.IsEpisode() {
+			episodes.PostIds = append(episodes.PostIds, post.ID)
+			episodes.DurationSeconds = append(episodes.DurationSeconds, item.DurationSeconds())
+			episodes.Seasons = append(episodes.Seasons, item.Season())
+			episodes.Episodes = append(episodes.Episodes, item.Episode())
+			episodes.Explicits = append(episodes.Explicits, item.Explicit())
+			episodes.ImageUrls = append(episodes.ImageUrls, item.ITunesImage.Href)
+		}
+	}
+	if len(categories.PostIds) > 0 {
+		if err := b.q.CreatePostCategories(ctx, categories); err != nil {
+			return fmt.Errorf("error inserting post categories: %v", err)
+		}
+	}
+	if len(enclosures.PostIds) > 0 {
+		if err := b.q.CreatePostEnclosures(ctx, enclosures); err != nil {
+			return fmt.Errorf("error inserting post enclosures: %v", err)
+		}
+	}
+	if len(thumbnails.PostIds) > 0 {
+		if err := b.q.CreatePostThumbnails(ctx, thumbnails); err != nil {
+			return fmt.Errorf("error inserting post thumbnails: %v", err)
+		}
+	}
+	if len(episodes.PostIds) > 0 {
+		if err := b.q.CreatePodcastEpisodes(ctx, episodes); err != nil {
+			return fmt.Errorf("error inserting podcast episodes: %v", err)
+		}
+	}
+	return nil
+}
+
+// pubDateLayouts are the date formats seen in the wild, most common first.
+var pubDateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	time.RFC3339,
+}
+
+func parsePubDate(s string) (time.Time, bool) {
+	for _, layout := range pubDateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,296 @@
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JadedPigeon/Gator/internal/config"
+	"github.com/JadedPigeon/Gator/internal/database"
+	"github.com/JadedPigeon/Gator/internal/dbtest"
+	"github.com/JadedPigeon/Gator/internal/rss"
+	"github.com/google/uuid"
+)
+
+// aggState returns a State for agg on a fresh database, with n feeds added
+// by one user. Each feed is served by its own path on srv, if given.
+func aggState(t *testing.T, n int, srv *httptest.Server) (*State, []database.Feed) {
+	t.Helper()
+	conn, db := dbtest.Queries(t)
+	ctx := context.Background()
+	now := time.Now()
+	user, err := db.CreateUser(ctx, database.CreateUserParams{ID: uuid.New(), CreatedAt: now, UpdatedAt: now, Name: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := "https://example.com"
+	if srv != nil {
+		base = srv.URL
+	}
+	feeds := make([]database.Feed, n)
+	for i := range feeds {
+		feeds[i], err = db.CreateFeed(ctx, database.CreateFeedParams{
+			Name:   fmt.Sprintf("Feed %d", i),
+			Url:    fmt.Sprintf("%s/feed/%d", base, i),
+			UserID: user.ID,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := &State{
+		Cfg:  &config.Config{},
+		DB:   db,
+		Conn: conn,
+		Out:  io.Discard,
+		Log:  slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	return s, feeds
+}
+
+func TestClaimNextFeedConcurrent(t *testing.T) {
+	const feeds, claimers = 40, 8
+	s, _ := aggState(t, feeds, nil)
+
+	var mu sync.Mutex
+	claims := make(map[uuid.UUID][]string)
+	var wg sync.WaitGroup
+	for i := 0; i < claimers; i++ {
+		owner := fmt.Sprintf("agg-%d", i)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Nobody releases, so each claim must find a feed no one holds
+			for {
+				feed, err := s.DB.ClaimNextFeed(context.Background(), database.ClaimNextFeedParams{
+					Owner:        owner,
+					LeaseSeconds: 600,
+				})
+				if err == sql.ErrNoRows {
+					return
+				}
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				mu.Lock()
+				claims[feed.ID] = append(claims[feed.ID], owner)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(claims) != feeds {
+		t.Errorf("%d of %d feeds were claimed", len(claims), feeds)
+	}
+	for id, owners := range claims {
+		if len(owners) != 1 {
+			t.Errorf("feed %s was handed out %d times, to %v", id, len(owners), owners)
+		}
+	}
+}
+
+func TestScrapeFeedsConcurrent(t *testing.T) {
+	const feeds, workers = 12, 4
+	var mu sync.Mutex
+	hits := make(map[string]int)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits[r.URL.Path]++
+		mu.Unlock()
+		// Slow enough that the workers overlap
+		time.Sleep(50 * time.Millisecond)
+		fmt.Fprintf(w, `<rss><channel><title>T</title><item><title>Post</title><link>https://example.com%s/1</link></item></channel></rss>`, r.URL.Path)
+	}))
+	defer srv.Close()
+	s, _ := aggState(t, feeds, srv)
+	// agg builds the fetcher before starting workers
+	if _, err := s.feedFetcher(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each worker scrapes its share once, as aggTick does; between them
+	// they must fetch every feed exactly once
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		owner := fmt.Sprintf("agg-%d", i)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < feeds/workers; j++ {
+				if err := scrapeFeeds(context.Background(), s, owner); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(hits) != feeds {
+		t.Errorf("%d of %d feeds were fetched", len(hits), feeds)
+	}
+	for path, n := range hits {
+		if n != 1 {
+			t.Errorf("%s was fetched %d times", path, n)
+		}
+	}
+	var leased, posts int
+	if err := s.Conn.QueryRow("SELECT COUNT(*) FROM feeds WHERE leased_by IS NOT NULL").Scan(&leased); err != nil {
+		t.Fatal(err)
+	}
+	if leased != 0 {
+		t.Errorf("%d feeds still leased after scraping", leased)
+	}
+	if err := s.Conn.QueryRow("SELECT COUNT(*) FROM posts").Scan(&posts); err != nil {
+		t.Fatal(err)
+	}
+	if posts != feeds {
+		t.Errorf("%d posts stored, want %d", posts, feeds)
+	}
+}
+
+func TestKeepFeedLease(t *testing.T) {
+	s, _ := aggState(t, 1, nil)
+	ctx := context.Background()
+	const lease = 3 * time.Second
+	feed, err := s.DB.ClaimNextFeed(ctx, database.ClaimNextFeedParams{Owner: "me", LeaseSeconds: int32(lease.Seconds())})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiry := func() time.Time {
+		t.Helper()
+		var at time.Time
+		if err := s.Conn.QueryRow("SELECT lease_expires_at FROM feeds WHERE id = $1", feed.ID).Scan(&at); err != nil {
+			t.Fatal(err)
+		}
+		return at
+	}
+	first := expiry()
+
+	stop := keepFeedLease(ctx, s, s.Log, feed.ID, "me", lease)
+	time.Sleep(lease/3 + 500*time.Millisecond)
+	stop()
+	if renewed := expiry(); !renewed.After(first) {
+		t.Errorf("lease still expires at %v, want it pushed back from %v", renewed, first)
+	}
+
+	// Another aggregator's lease is left alone
+	n, err := s.DB.RenewFeedLease(ctx, database.RenewFeedLeaseParams{LeaseSeconds: 600, ID: feed.ID, Owner: "someone-else"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Error("renewed a lease held by another owner")
+	}
+}
+
+func TestIngestFeedBeginsTransactionLazily(t *testing.T) {
+	s, feeds := aggState(t, 1, nil)
+	ctx := context.Background()
+	inTx := func() bool { return s.Conn.Stats().InUse > 0 }
+
+	stats, err := ingestFeed(ctx, s, feeds[0].ID, func(fn func(rss.RSSItem) error) error {
+		// Waiting for the server, or for the first batch to fill up, must not
+		// hold a transaction
+		for i := 0; i < postBatchSize; i++ {
+			if inTx() {
+				t.Fatalf("transaction open after %d items", i)
+			}
+			item := rss.RSSItem{Title: fmt.Sprintf("Post %d", i), Link: fmt.Sprintf("https://example.com/%d", i)}
+			if err := fn(item); err != nil {
+				return err
+			}
+		}
+		if !inTx() {
+			t.Error("no transaction open after the first batch was inserted")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Inserted != postBatchSize {
+		t.Errorf("inserted %d posts, want %d", stats.Inserted, postBatchSize)
+	}
+	if inTx() {
+		t.Error("transaction still open after ingestFeed returned")
+	}
+
+	// A feed with no items is still marked as fetched
+	if _, err := s.Conn.Exec("UPDATE feeds SET last_fetched_at = NULL WHERE id = $1", feeds[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ingestFeed(ctx, s, feeds[0].ID, func(func(rss.RSSItem) error) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	var fetched sql.NullTime
+	if err := s.Conn.QueryRow("SELECT last_fetched_at FROM feeds WHERE id = $1", feeds[0].ID).Scan(&fetched); err != nil {
+		t.Fatal(err)
+	}
+	if !fetched.Valid {
+		t.Error("empty feed was not marked as fetched")
+	}
+}
+
+func TestAggLockIsOptIn(t *testing.T) {
+	// Leases let aggregators share a database, so none is locked out by
+	// default
+	fs := NewCommands().Info["agg"].flagSet("agg")
+	for _, name := range []string{"lock", "lock-file"} {
+		f := fs.Lookup(name)
+		if f == nil {
+			t.Fatalf("agg has no --%s flag", name)
+		}
+		if f.DefValue != "false" && f.DefValue != "" {
+			t.Errorf("--%s defaults to %q", name, f.DefValue)
+		}
+	}
+}
+
+func TestFeedQueueLag(t *testing.T) {
+	s, feeds := aggState(t, 2, nil)
+	ctx := context.Background()
+	// The feed next in line was tried two hours ago, the other just now
+	_, err := s.Conn.Exec("UPDATE feeds SET last_attempted_at = NOW() - INTERVAL '2 hours' WHERE id = $1", feeds[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Conn.Exec("UPDATE feeds SET last_attempted_at = NOW() WHERE id = $1", feeds[1].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		interval time.Duration
+		want     float64
+	}{
+		{time.Hour, 3600},
+		{2 * time.Hour, 0},
+		{3 * time.Hour, 0},
+	}
+	for _, tt := range tests {
+		lag, err := s.DB.GetFeedQueueLag(ctx, tt.interval.Seconds())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lag < tt.want || lag > tt.want+5 {
+			t.Errorf("interval %v: lag = %.1fs, want about %.0fs", tt.interval, lag, tt.want)
+		}
+	}
+
+	// A leased feed is being fetched, so it isn't waiting
+	_, err = s.DB.ClaimNextFeed(ctx, database.ClaimNextFeedParams{Owner: "me", LeaseSeconds: 600})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lag, err := s.DB.GetFeedQueueLag(ctx, 0); err != nil || lag > 5 {
+		t.Errorf("with the overdue feed leased: lag = %.1fs, %v; want about 0", lag, err)
+	}
+}
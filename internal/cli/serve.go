@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/JadedPigeon/Gator/internal/api"
+	"github.com/JadedPigeon/Gator/internal/database"
+	"github.com/JadedPigeon/Gator/internal/web"
+	"github.com/JadedPigeon/Gator/internal/websub"
+)
+
+// shutdownTimeout is how long in-flight requests get to finish on Ctrl+C.
+const shutdownTimeout = 5 * time.Second
+
+func HandlerServe(ctx context.Context, s *State, cmd Command) error {
+	mux := http.NewServeMux()
+	mux.Handle("/", (&api.Server{DB: s.DB, Log: s.Log}).Handler())
+	// Hubs call back here to confirm subscriptions and push new content
+	mux.Handle("/websub/", (&websub.Server{
+		DB:  s.DB,
+		Log: s.Log,
+		Ingest: func(ctx context.Context, sub database.WebsubSubscription, body io.Reader) error {
+			return ingestPush(ctx, s, sub, body)
+		},
+		MaxBodySize: s.Cfg.MaxFeedBytes,
+	}).Handler())
+	srv := &http.Server{
+		Addr:              cmd.FlagString("addr"),
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		ErrorLog:          slog.NewLogLogger(s.Log.Handler(), slog.LevelError),
+	}
+	return listenUntilDone(ctx, s, srv)
+}
+
+// listenUntilDone runs srv until ctx is cancelled, then shuts it down
+// gracefully.
+func listenUntilDone(ctx context.Context, s *State, srv *http.Server) error {
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.ListenAndServe()
+	}()
+	s.Log.Info("Listening", "addr", "http://"+srv.Addr)
+
+	select {
+	case err := <-errc:
+		return fmt.Errorf("error serving: %v", err)
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("error shutting down: %v", err)
+	}
+	s.Log.Info("Server stopped")
+	return nil
+}
+
+func HandlerWeb(ctx context.Context, s *State, cmd Command) error {
+	srv := &http.Server{
+		Addr:              cmd.FlagString("addr"),
+		Handler:           (&web.Server{DB: s.DB, Log: s.Log}).Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		ErrorLog:          slog.NewLogLogger(s.Log.Handler(), slog.LevelError),
+	}
+	return listenUntilDone(ctx, s, srv)
+}
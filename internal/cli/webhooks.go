@@ -0,0 +1,203 @@
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/JadedPigeon/Gator/internal/database"
+	"github.com/JadedPigeon/Gator/internal/webhook"
+	"github.com/google/uuid"
+)
+
+// webhookBatchSize is how many due deliveries agg sends per tick.
+const webhookBatchSize = 50
+
+func HandlerWebhookAdd(ctx context.Context, s *State, cmd Command, user database.User) error {
+	params := database.CreateWebhookParams{
+		UserID:      user.ID,
+		Url:         cmd.Args[0],
+		Category:    optionalString(cmd.FlagString("category")),
+		Keyword:     optionalString(cmd.FlagString("keyword")),
+		ContentType: optionalString(cmd.FlagString("content-type")),
+	}
+	if err := checkWebhookURL(params.Url); err != nil {
+		return err
+	}
+	if feedURL := cmd.FlagString("feed"); feedURL != "" {
+		feed, err := s.DB.GetFeedByUrl(ctx, feedURL)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("feed with URL %s does not exist", feedURL)
+			}
+			return fmt.Errorf("error checking feed: %v", err)
+		}
+		params.FeedID = uuid.NullUUID{UUID: feed.ID, Valid: true}
+	}
+	if path := cmd.FlagString("template"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("error reading template: %v", err)
+		}
+		if _, err := webhook.ParseTemplate(string(data)); err != nil {
+			return err
+		}
+		params.Template = sql.NullString{String: string(data), Valid: true}
+	}
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		return fmt.Errorf("error generating webhook secret: %v", err)
+	}
+	params.Secret = secret
+
+	created, err := s.DB.CreateWebhook(ctx, params)
+	if err != nil {
+		return fmt.Errorf("error creating webhook: %v", err)
+	}
+	fmt.Printf("Webhook %s added. Payloads are signed with this secret:\n", created.ID)
+	fmt.Println(created.Secret)
+	return nil
+}
+
+func checkWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid webhook URL %q: must be an http or https URL", raw)
+	}
+	return nil
+}
+
+func HandlerWebhooks(ctx context.Context, s *State, cmd Command, user database.User) error {
+	hooks, err := s.DB.GetWebhooksForUser(ctx, user.ID)
+	if err != nil {
+		return fmt.Errorf("error retrieving webhooks: %v", err)
+	}
+	records := Records{
+		Columns: []string{"id", "url", "feed_url", "category", "keyword", "template", "content_type"},
+		Empty:   "No webhooks found.",
+	}
+	for _, hook := range hooks {
+		records.Add(hook.ID, hook.Url, hook.FeedUrl, hook.Category, hook.Keyword, hook.Template.Valid, hook.ContentType)
+	}
+	return s.Emit(records)
+}
+
+func HandlerWebhookDelete(ctx context.Context, s *State, cmd Command, user database.User) error {
+	id, err := uuid.Parse(cmd.Args[0])
+	if err != nil {
+		return fmt.Errorf("invalid webhook ID %q", cmd.Args[0])
+	}
+	deleted, err := s.DB.DeleteWebhook(ctx, database.DeleteWebhookParams{
+		ID:     id,
+		UserID: user.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("error deleting webhook: %v", err)
+	}
+	if deleted == 0 {
+		return fmt.Errorf("webhook %s does not exist", id)
+	}
+	fmt.Printf("Webhook %s deleted\n", id)
+	return nil
+}
+
+func HandlerWebhookLog(ctx context.Context, s *State, cmd Command, user database.User) error {
+	limit := cmd.FlagInt("limit")
+	if limit <= 0 {
+		return errors.New("limit must be a positive integer")
+	}
+	deliveries, err := s.DB.GetWebhookDeliveriesForUser(ctx, database.GetWebhookDeliveriesForUserParams{
+		UserID: user.ID,
+		Limit:  int32(limit),
+	})
+	if err != nil {
+		return fmt.Errorf("error retrieving webhook deliveries: %v", err)
+	}
+	records := Records{
+		Columns: []string{"id", "created_at", "webhook_url", "post", "status", "attempts", "response_code", "last_error", "delivered_at"},
+		Empty:   "No webhook deliveries yet.",
+	}
+	for _, d := range deliveries {
+		records.Add(d.ID, d.CreatedAt, d.WebhookUrl, d.PostTitle, d.Status, d.Attempts, d.ResponseCode, d.LastError, d.DeliveredAt)
+	}
+	return s.Emit(records)
+}
+
+// deliverWebhooks sends deliveries that are due, recording each outcome.
+// Failed ones are retried with exponential backoff until MaxAttempts.
+func deliverWebhooks(ctx context.Context, s *State) error {
+	// Claimed long enough to send the whole batch even if every request
+	// times out
+	claim := webhookBatchSize*s.Cfg.FetchTimeout.Or(defaultFetchTimeout) + time.Minute
+	deliveries, err := s.DB.ClaimWebhookDeliveries(ctx, database.ClaimWebhookDeliveriesParams{
+		ClaimSeconds: int32(claim.Seconds()),
+		Limit:        webhookBatchSize,
+	})
+	if err != nil {
+		return fmt.Errorf("error retrieving webhook deliveries: %v", err)
+	}
+	if len(deliveries) == 0 {
+		return nil
+	}
+	fetcher, err := s.feedFetcher()
+	if err != nil {
+		return err
+	}
+	for _, d := range deliveries {
+		payload := webhook.Payload{
+			Event: webhook.EventNewPost,
+			Feed:  webhook.Feed{Name: d.FeedName, URL: d.FeedUrl},
+			Post: webhook.Post{
+				ID:          d.PostID.String(),
+				Title:       d.Title,
+				URL:         d.PostUrl,
+				Description: d.Description.String,
+				Author:      d.Author.String,
+			},
+		}
+		if d.PublishedAt.Valid {
+			payload.Post.PublishedAt = &d.PublishedAt.Time
+		}
+		maxAttempts := webhook.MaxAttempts
+		body, err := webhook.Render(d.Template.String, payload)
+		status := 0
+		if err != nil {
+			// Retrying won't fix a broken template
+			maxAttempts = 0
+		} else {
+			contentType := webhook.ContentType(d.ContentType.String, d.Template.String)
+			status, err = webhook.Send(ctx, fetcher, d.WebhookUrl, contentType, d.Secret, d.ID.String(), body)
+		}
+		if ctx.Err() != nil {
+			// Interrupted, not failed; it is sent again once the claim
+			// runs out
+			return nil
+		}
+		code := sql.NullInt32{Int32: int32(status), Valid: status != 0}
+		if err == nil {
+			if err := s.DB.MarkWebhookDelivered(ctx, database.MarkWebhookDeliveredParams{ID: d.ID, ResponseCode: code}); err != nil {
+				return fmt.Errorf("error recording webhook delivery: %v", err)
+			}
+			continue
+		}
+		recordErr := s.DB.RecordWebhookFailure(ctx, database.RecordWebhookFailureParams{
+			ResponseCode: code,
+			LastError:    sql.NullString{String: err.Error(), Valid: true},
+			MaxAttempts:  int32(maxAttempts),
+			RetrySeconds: int32(webhook.Backoff(int(d.Attempts) + 1).Seconds()),
+			ID:           d.ID,
+		})
+		if recordErr != nil {
+			return fmt.Errorf("error recording webhook failure: %v", recordErr)
+		}
+		if int(d.Attempts)+1 >= maxAttempts {
+			s.Log.Warn("Gave up on webhook delivery", "webhook_url", d.WebhookUrl, "post_id", d.PostID,
+				"post_title", d.Title, "error", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package cli
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/JadedPigeon/Gator/internal/database"
+	"github.com/JadedPigeon/Gator/internal/webhook"
+	"github.com/google/uuid"
+)
+
+func TestDeliverWebhooksGivesUp(t *testing.T) {
+	var requests atomic.Int32
+	var contentType atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		contentType.Store(r.Header.Get("Content-Type"))
+		http.Error(w, "down for maintenance", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	s, feeds := aggState(t, 1, nil)
+	ctx := context.Background()
+
+	user, err := s.DB.GetUser(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.DB.CreateFeedFollow(ctx, database.CreateFeedFollowParams{FeedID: feeds[0].ID, UserID: user.ID}); err != nil {
+		t.Fatal(err)
+	}
+	// The keyword's % must match itself, not any text
+	if _, err := s.DB.CreateWebhook(ctx, database.CreateWebhookParams{
+		UserID:  user.ID,
+		Url:     srv.URL,
+		Secret:  "secret",
+		Keyword: optionalString("50%"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	posts, err := s.DB.CreatePosts(ctx, database.CreatePostsParams{
+		FeedID:       feeds[0].ID,
+		Titles:       []string{"50% off", "500 offers"},
+		Urls:         []string{"https://example.com/sale", "https://example.com/offers"},
+		Descriptions: []string{"", ""},
+		PublishedAts: []string{"", ""},
+		Authors:      []string{"", ""},
+		CommentsUrls: []string{"", ""},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []uuid.UUID{posts[0].ID, posts[1].ID}
+	if n, err := s.DB.EnqueueWebhookDeliveries(ctx, ids); err != nil || n != 1 {
+		t.Fatalf("enqueued %d deliveries (%v), want 1", n, err)
+	}
+
+	for attempt := 1; attempt <= webhook.MaxAttempts; attempt++ {
+		if err := deliverWebhooks(ctx, s); err != nil {
+			t.Fatal(err)
+		}
+		var attempts int
+		var status string
+		var wait float64
+		err := s.Conn.QueryRow(`SELECT attempts, status, EXTRACT(EPOCH FROM next_attempt_at - NOW())
+			FROM webhook_deliveries`).Scan(&attempts, &status, &wait)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if attempts != attempt {
+			t.Fatalf("attempts = %d after attempt %d", attempts, attempt)
+		}
+		wantStatus := "pending"
+		if attempt == webhook.MaxAttempts {
+			wantStatus = "failed"
+		}
+		if status != wantStatus {
+			t.Fatalf("status = %q after attempt %d, want %q", status, attempt, wantStatus)
+		}
+		if backoff := webhook.Backoff(attempt).Seconds(); wait < backoff-5 || wait > backoff+5 {
+			t.Errorf("next attempt in %.0fs after attempt %d, want about %.0fs", wait, attempt, backoff)
+		}
+		// Skip the wait
+		if _, err := s.Conn.Exec("UPDATE webhook_deliveries SET next_attempt_at = NOW()"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A failed delivery is not tried again
+	if err := deliverWebhooks(ctx, s); err != nil {
+		t.Fatal(err)
+	}
+	if n := requests.Load(); n != webhook.MaxAttempts {
+		t.Errorf("webhook got %d requests, want %d", n, webhook.MaxAttempts)
+	}
+	if ct := contentType.Load(); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+}
@@ -0,0 +1,136 @@
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"net/url"
+
+	"github.com/JadedPigeon/Gator/internal/database"
+	"github.com/JadedPigeon/Gator/internal/rss"
+	"github.com/JadedPigeon/Gator/internal/websub"
+)
+
+// subscribeWebSub asks the hub a fetched feed advertises to push updates,
+// unless it has already asked that hub. Renewal, and asking again after a
+// hub refused, is left to renewWebSub.
+func subscribeWebSub(ctx context.Context, s *State, feed database.Feed, channel *rss.RSSChannel) error {
+	if s.Cfg.WebSubCallbackURL == "" || channel == nil || channel.Hub == "" {
+		return nil
+	}
+	base, err := url.Parse(feed.Url)
+	if err != nil {
+		return err
+	}
+	hub, err := base.Parse(channel.Hub)
+	if err != nil {
+		return fmt.Errorf("invalid hub URL: %v", err)
+	}
+	// Hubs know the feed by its self link, which may differ from the URL
+	// it was added with
+	topic := base
+	if channel.Self != "" {
+		if topic, err = base.Parse(channel.Self); err != nil {
+			return fmt.Errorf("invalid self URL: %v", err)
+		}
+	}
+
+	existing, err := s.DB.GetWebSubSubscriptionForFeed(ctx, feed.ID)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("error retrieving WebSub subscription: %v", err)
+	}
+	if err == nil && existing.HubUrl == hub.String() && existing.TopicUrl == topic.String() {
+		return nil
+	}
+	secret, err := websub.NewSecret()
+	if err != nil {
+		return fmt.Errorf("error generating WebSub secret: %v", err)
+	}
+	return requestWebSub(ctx, s, database.RequestWebSubSubscriptionParams{
+		FeedID:   feed.ID,
+		HubUrl:   hub.String(),
+		TopicUrl: topic.String(),
+		Secret:   secret,
+	})
+}
+
+// renewWebSub resubscribes before leases run out and retries requests the
+// hub never confirmed or denied a while ago.
+func renewWebSub(ctx context.Context, s *State) error {
+	if s.Cfg.WebSubCallbackURL == "" {
+		return nil
+	}
+	subs, err := s.DB.GetWebSubSubscriptionsToRenew(ctx)
+	if err != nil {
+		return fmt.Errorf("error retrieving WebSub subscriptions: %v", err)
+	}
+	for _, sub := range subs {
+		err := requestWebSub(ctx, s, database.RequestWebSubSubscriptionParams{
+			FeedID:   sub.FeedID,
+			HubUrl:   sub.HubUrl,
+			TopicUrl: sub.TopicUrl,
+			Secret:   sub.Secret,
+		})
+		if err != nil {
+			s.Log.Warn("WebSub renewal failed", "feed_id", sub.FeedID, "feed_url", sub.TopicUrl, "error", err)
+		}
+	}
+	return nil
+}
+
+// requestWebSub records a pending subscription and sends it to the hub.
+// The row comes first because hubs may verify before they respond.
+func requestWebSub(ctx context.Context, s *State, params database.RequestWebSubSubscriptionParams) error {
+	sub, err := s.DB.RequestWebSubSubscription(ctx, params)
+	if err != nil {
+		return fmt.Errorf("error saving WebSub subscription: %v", err)
+	}
+	fetcher, err := s.feedFetcher()
+	if err != nil {
+		return err
+	}
+	callback := websub.CallbackURL(s.Cfg.WebSubCallbackURL, sub.ID)
+	if err := websub.Subscribe(ctx, fetcher, sub.HubUrl, sub.TopicUrl, callback, sub.Secret); err != nil {
+		failErr := s.DB.FailWebSubSubscription(ctx, database.FailWebSubSubscriptionParams{
+			ID:        sub.ID,
+			LastError: sql.NullString{String: err.Error(), Valid: true},
+		})
+		if failErr != nil {
+			return fmt.Errorf("error saving WebSub subscription: %v", failErr)
+		}
+		return err
+	}
+	s.Log.Info("Requested WebSub subscription", "feed_id", sub.FeedID, "feed_url", sub.TopicUrl, "hub_url", sub.HubUrl)
+	return nil
+}
+
+// ingestPush stores content a hub pushed, like a fetch by agg.
+func ingestPush(ctx context.Context, s *State, sub database.WebsubSubscription, body io.Reader) error {
+	stats, err := ingestFeed(ctx, s, sub.FeedID, func(fn func(rss.RSSItem) error) error {
+		_, err := rss.Stream(body, fn)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("error storing pushed content for %s: %v", sub.TopicUrl, err)
+	}
+	s.Log.Info("Stored pushed feed", "feed_id", sub.FeedID, "feed_url", sub.TopicUrl, "items", stats.Items(),
+		"inserted", stats.Inserted, "skipped", stats.Skipped, "failed", stats.Failed)
+	runAlerts(ctx, s, stats.Alerts)
+	return nil
+}
+
+func HandlerWebSub(ctx context.Context, s *State, cmd Command) error {
+	subs, err := s.DB.GetWebSubSubscriptions(ctx)
+	if err != nil {
+		return fmt.Errorf("error retrieving WebSub subscriptions: %v", err)
+	}
+	records := Records{
+		Columns: []string{"id", "feed", "feed_url", "hub_url", "state", "lease_expires_at", "last_error"},
+		Empty:   "No WebSub subscriptions.",
+	}
+	for _, sub := range subs {
+		records.Add(sub.ID, sub.FeedName, sub.FeedUrl, sub.HubUrl, sub.State, sub.LeaseExpiresAt, sub.LastError)
+	}
+	return s.Emit(records)
+}
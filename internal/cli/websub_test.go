@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/JadedPigeon/Gator/internal/database"
+	"github.com/JadedPigeon/Gator/internal/websub"
+)
+
+func TestRenewWebSubRetriesDenied(t *testing.T) {
+	var requests atomic.Int32
+	hub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer hub.Close()
+	s, feeds := aggState(t, 1, nil)
+	s.Cfg.WebSubCallbackURL = "https://gator.example.com"
+	ctx := context.Background()
+
+	secret, err := websub.NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := s.DB.RequestWebSubSubscription(ctx, database.RequestWebSubSubscriptionParams{
+		FeedID: feeds[0].ID, HubUrl: hub.URL, TopicUrl: feeds[0].Url, Secret: secret,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DB.DenyWebSubSubscription(ctx, database.DenyWebSubSubscriptionParams{ID: sub.ID}); err != nil {
+		t.Fatal(err)
+	}
+	backdate := func(interval string) {
+		t.Helper()
+		_, err := s.Conn.Exec("UPDATE websub_subscriptions SET updated_at = NOW() - $1::interval WHERE id = $2", interval, sub.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A fresh denial is left alone
+	backdate("2 hours")
+	if err := renewWebSub(ctx, s); err != nil {
+		t.Fatal(err)
+	}
+	if n := requests.Load(); n != 0 {
+		t.Fatalf("hub asked again %d times two hours after denying", n)
+	}
+
+	// A day later the hub is asked again, and the subscription is pending,
+	// so the callback accepts the hub's verification once more
+	backdate("25 hours")
+	if err := renewWebSub(ctx, s); err != nil {
+		t.Fatal(err)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Fatalf("hub asked %d times a day after denying, want 1", n)
+	}
+	got, err := s.DB.GetWebSubSubscription(ctx, sub.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.State != "pending" {
+		t.Errorf("state = %q after asking again, want pending", got.State)
+	}
+}
@@ -2,8 +2,10 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 const configFileName = ".gatorconfig.json"
@@ -11,6 +13,82 @@ const configFileName = ".gatorconfig.json"
 type Config struct {
 	DBURL       string `json:"db_url"`
 	CurrentUser string `json:"current_user_name"`
+	// CommandTimeout bounds how long a single command may run, apart from
+	// long-running ones like agg.
+	CommandTimeout Duration `json:"command_timeout,omitzero"`
+	// FetchTimeout bounds a single feed fetch.
+	FetchTimeout Duration `json:"fetch_timeout,omitzero"`
+
+	// Feed fetching
+	UserAgent    string                       `json:"user_agent,omitempty"`
+	MaxRedirects int                          `json:"max_redirects,omitempty"`
+	ProxyURL     string                       `json:"proxy_url,omitempty"`
+	CABundle     string                       `json:"ca_bundle,omitempty"`
+	HostHeaders  map[string]map[string]string `json:"host_headers,omitempty"`
+	// MaxFeedBytes caps a feed's size on the wire; MaxFeedDecodedBytes caps
+	// it after decompression.
+	MaxFeedBytes         int64 `json:"max_feed_bytes,omitempty"`
+	MaxFeedDecodedBytes  int64 `json:"max_feed_decoded_bytes,omitempty"`
+	BlockPrivateNetworks bool  `json:"block_private_networks,omitempty"`
+
+	// DownloadDir is where the download command saves podcast episodes.
+	DownloadDir string `json:"download_dir,omitempty"`
+
+	// WebSubCallbackURL is the public address of the serve command. When
+	// set, agg subscribes to feeds that name a WebSub hub.
+	WebSubCallbackURL string `json:"websub_callback_url,omitempty"`
+
+	// AlertCommand is run through sh -c for each post matching an alert
+	// filter rule.
+	AlertCommand string `json:"alert_command,omitempty"`
+
+	// SMTP is the mail server that digests are sent through.
+	SMTP SMTPConfig `json:"smtp,omitzero"`
+
+	// LogLevel and LogFormat set up diagnostics on stderr; the global
+	// --log-level and --log-format flags override them.
+	LogLevel  string `json:"log_level,omitempty"`
+	LogFormat string `json:"log_format,omitempty"`
+}
+
+type SMTPConfig struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	// From is the sender, e.g. "Gator <gator@example.com>".
+	From        string `json:"from"`
+	ImplicitTLS bool   `json:"implicit_tls,omitempty"`
+}
+
+// Duration is a time.Duration stored in the config as a string like "30s".
+type Duration struct {
+	time.Duration
+}
+
+// Or returns the duration, or def if it isn't set.
+func (d Duration) Or(def time.Duration) time.Duration {
+	if d.Duration <= 0 {
+		return def
+	}
+	return d.Duration
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"30s\": %v", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
 }
 
 // Exported: starts with capital letter
@@ -44,7 +122,12 @@ func write(cfg Config) error {
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(path, data, 0644)
+	// The file can hold the SMTP password, so only its owner may read it.
+	// WriteFile keeps the mode of an existing file, hence the Chmod.
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return err
+	}
+	return os.Chmod(path, 0600)
 }
 
 func getConfigFilePath() (string, error) {
@@ -0,0 +1,39 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteIsPrivate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	t.Setenv("GATOR_CONFIG_PATH", path)
+	// A config from before it held secrets may be world-readable
+	if err := os.WriteFile(path, []byte(`{"db_url": "postgres://localhost/gator"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.SMTP.Password = "secret"
+	if err := cfg.SetUser("alice"); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0o600 {
+		t.Errorf("config file mode = %o, want 600", mode)
+	}
+
+	again, err := Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.CurrentUser != "alice" || again.SMTP.Password != "secret" {
+		t.Errorf("read back %+v", again)
+	}
+}
@@ -0,0 +1,103 @@
+// Package daemon has what agg needs to run as a system service: an
+// optional lock file so only one aggregator per database runs on a machine,
+// and systemd readiness notifications.
+package daemon
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// LockPath returns the default lock file for an aggregator using dbURL,
+// named after a hash of the URL so each database gets its own.
+func LockPath(dbURL string) string {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	sum := sha256.Sum256([]byte(dbURL))
+	return filepath.Join(dir, "gator-agg-"+hex.EncodeToString(sum[:6])+".pid")
+}
+
+// LockedError is returned by Acquire when another process holds the lock.
+type LockedError struct {
+	Path string
+	PID  int // 0 if unknown
+}
+
+func (e *LockedError) Error() string {
+	if e.PID == 0 {
+		return fmt.Sprintf("another aggregator is already running (lock file %s)", e.Path)
+	}
+	return fmt.Sprintf("another aggregator is already running as pid %d (lock file %s)", e.PID, e.Path)
+}
+
+// Lock is a held lock file containing our PID.
+type Lock struct {
+	f    *os.File
+	path string
+}
+
+// Acquire takes the lock file at path and writes the current PID to it.
+// The lock belongs to the open file, so it is let go even if the process
+// is killed; a leftover file from a crash doesn't block the next start.
+func Acquire(path string) (*Lock, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("error opening lock file: %v", err)
+	}
+	if err := tryLock(f); err != nil {
+		defer f.Close()
+		if err == errLocked {
+			data, _ := io.ReadAll(io.LimitReader(f, 32))
+			pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+			return nil, &LockedError{Path: path, PID: pid}
+		}
+		return nil, fmt.Errorf("error locking %s: %v", path, err)
+	}
+	if err := f.Truncate(0); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("error writing lock file: %v", err)
+	}
+	if _, err := f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("error writing lock file: %v", err)
+	}
+	return &Lock{f: f, path: path}, nil
+}
+
+// Release removes the lock file and lets go of the lock.
+func (l *Lock) Release() error {
+	// Removed while still locked, so no one else can lock it in between
+	os.Remove(l.path)
+	return l.f.Close()
+}
+
+// Notify sends state, such as "READY=1" or "STOPPING=1", to systemd. It
+// does nothing unless systemd started us with Type=notify.
+func Notify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	// A leading @ names a socket in the abstract namespace
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("error notifying systemd: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return fmt.Errorf("error notifying systemd: %v", err)
+	}
+	return nil
+}
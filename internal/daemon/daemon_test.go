@@ -0,0 +1,98 @@
+//go:build unix
+
+package daemon
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestAcquireTwice(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agg.pid")
+	lock, err := Acquire(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Acquire(path)
+	var locked *LockedError
+	if !errors.As(err, &locked) {
+		t.Fatalf("second Acquire = %v, want a *LockedError", err)
+	}
+	if locked.PID != os.Getpid() || locked.Path != path {
+		t.Errorf("LockedError = %+v, want pid %d and path %s", locked, os.Getpid(), path)
+	}
+
+	if err := lock.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("lock file still there after Release: %v", err)
+	}
+	lock, err = Acquire(path)
+	if err != nil {
+		t.Fatalf("Acquire after Release: %v", err)
+	}
+	lock.Release()
+}
+
+func TestAcquireLeftoverFile(t *testing.T) {
+	// A file left by a crashed aggregator holds no lock
+	path := filepath.Join(t.TempDir(), "agg.pid")
+	if err := os.WriteFile(path, []byte("999999999\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	lock, err := Acquire(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.Release()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := strconv.Itoa(os.Getpid()) + "\n"; string(data) != want {
+		t.Errorf("lock file = %q, want %q", data, want)
+	}
+}
+
+func TestNotify(t *testing.T) {
+	// Socket paths have to be short, which t.TempDir's may not be
+	dir, err := os.MkdirTemp("", "gator")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	t.Setenv("NOTIFY_SOCKET", socket)
+
+	if err := Notify("READY=1"); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 64)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(buf[:n]); got != "READY=1" {
+		t.Errorf("got %q, want READY=1", got)
+	}
+}
+
+func TestNotifyWithoutSocket(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	if err := Notify("READY=1"); err != nil {
+		t.Errorf("Notify without NOTIFY_SOCKET: %v", err)
+	}
+}
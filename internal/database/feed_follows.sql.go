@@ -7,6 +7,7 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
@@ -54,7 +55,7 @@ func (q *Queries) CreateFeedFollow(ctx context.Context, arg CreateFeedFollowPara
 	return i, err
 }
 
-const deleteFeedFollow = `-- name: DeleteFeedFollow :exec
+const deleteFeedFollow = `-- name: DeleteFeedFollow :execrows
   DELETE FROM feed_follows
   WHERE feed_id = $1 AND user_id = $2
 `
@@ -64,9 +65,12 @@ type DeleteFeedFollowParams struct {
 	UserID uuid.UUID
 }
 
-func (q *Queries) DeleteFeedFollow(ctx context.Context, arg DeleteFeedFollowParams) error {
-	_, err := q.db.ExecContext(ctx, deleteFeedFollow, arg.FeedID, arg.UserID)
-	return err
+func (q *Queries) DeleteFeedFollow(ctx context.Context, arg DeleteFeedFollowParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteFeedFollow, arg.FeedID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
 
 const getFeedFollowsForUser = `-- name: GetFeedFollowsForUser :many
@@ -75,7 +79,7 @@ SELECT
     feed_follows.created_at,
     feed_follows.updated_at,
     feeds.id AS feed_id,
-    feeds.name AS feed_name,
+    COALESCE(feed_follows.alias, feeds.name) AS feed_name,
     feeds.url AS feed_url,
     users.id AS user_id,
     users.name AS user_name
@@ -128,3 +132,49 @@ func (q *Queries) GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) (
 	}
 	return items, nil
 }
+
+const isFollowingFeed = `-- name: IsFollowingFeed :one
+SELECT EXISTS (
+    SELECT 1 FROM feed_follows
+    WHERE feed_id = $1 AND user_id = $2
+) AS following
+`
+
+type IsFollowingFeedParams struct {
+	FeedID uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) IsFollowingFeed(ctx context.Context, arg IsFollowingFeedParams) (bool, error) {
+	row := q.db.QueryRowContext(ctx, isFollowingFeed, arg.FeedID, arg.UserID)
+	var following bool
+	err := row.Scan(&following)
+	return following, err
+}
+
+const setFeedFollowAlias = `-- name: SetFeedFollowAlias :one
+UPDATE feed_follows
+SET alias = $3, updated_at = NOW()
+WHERE feed_id = $1 AND user_id = $2
+RETURNING id, created_at, updated_at, feed_id, user_id, alias
+`
+
+type SetFeedFollowAliasParams struct {
+	FeedID uuid.UUID
+	UserID uuid.UUID
+	Alias  sql.NullString
+}
+
+func (q *Queries) SetFeedFollowAlias(ctx context.Context, arg SetFeedFollowAliasParams) (FeedFollow, error) {
+	row := q.db.QueryRowContext(ctx, setFeedFollowAlias, arg.FeedID, arg.UserID, arg.Alias)
+	var i FeedFollow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.FeedID,
+		&i.UserID,
+		&i.Alias,
+	)
+	return i, err
+}
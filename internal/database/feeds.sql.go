@@ -12,10 +12,51 @@ import (
 	"github.com/google/uuid"
 )
 
+const claimNextFeed = `-- name: ClaimNextFeed :one
+UPDATE feeds
+SET last_attempted_at = NOW(),
+    leased_by = $1::text,
+    lease_expires_at = NOW() + make_interval(secs => $2::int)
+WHERE id = (
+    SELECT id FROM feeds
+    WHERE lease_expires_at IS NULL OR lease_expires_at < NOW()
+    ORDER BY last_attempted_at ASC NULLS FIRST
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, last_attempted_at, seq, leased_by, lease_expires_at
+`
+
+type ClaimNextFeedParams struct {
+	Owner        string
+	LeaseSeconds int32
+}
+
+// Leases the feed that has waited longest, skipping feeds another
+// aggregator holds, and moves it to the back of the queue.
+func (q *Queries) ClaimNextFeed(ctx context.Context, arg ClaimNextFeedParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, claimNextFeed, arg.Owner, arg.LeaseSeconds)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.LastAttemptedAt,
+		&i.Seq,
+		&i.LeasedBy,
+		&i.LeaseExpiresAt,
+	)
+	return i, err
+}
+
 const createFeed = `-- name: CreateFeed :one
 INSERT INTO feeds (name, url, user_id)
 VALUES ($1, $2, $3)
-RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, last_attempted_at, seq, leased_by, lease_expires_at
 `
 
 type CreateFeedParams struct {
@@ -35,6 +76,10 @@ func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, e
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.LastAttemptedAt,
+		&i.Seq,
+		&i.LeasedBy,
+		&i.LeaseExpiresAt,
 	)
 	return i, err
 }
@@ -123,25 +168,23 @@ func (q *Queries) GetFeedByUrl(ctx context.Context, url string) (GetFeedByUrlRow
 	return i, err
 }
 
-const getNextFeedToFetch = `-- name: GetNextFeedToFetch :one
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at FROM feeds
-ORDER BY last_fetched_at ASC NULLS FIRST
-LIMIT 1
+const getFeedQueueLag = `-- name: GetFeedQueueLag :one
+SELECT GREATEST(
+    COALESCE(EXTRACT(EPOCH FROM NOW() - MIN(COALESCE(last_attempted_at, created_at))), 0) - $1::float8,
+    0
+)::float8 AS lag_seconds
+FROM feeds
+WHERE lease_expires_at IS NULL OR lease_expires_at < NOW()
 `
 
-func (q *Queries) GetNextFeedToFetch(ctx context.Context) (Feed, error) {
-	row := q.db.QueryRowContext(ctx, getNextFeedToFetch)
-	var i Feed
-	err := row.Scan(
-		&i.ID,
-		&i.CreatedAt,
-		&i.UpdatedAt,
-		&i.Name,
-		&i.Url,
-		&i.UserID,
-		&i.LastFetchedAt,
-	)
-	return i, err
+// How far the feed next in line is overdue: how long it has waited since
+// it was last tried, or since it was added if it never was, less the fetch
+// interval. Zero when nothing is overdue.
+func (q *Queries) GetFeedQueueLag(ctx context.Context, intervalSeconds float64) (float64, error) {
+	row := q.db.QueryRowContext(ctx, getFeedQueueLag, intervalSeconds)
+	var lag_seconds float64
+	err := row.Scan(&lag_seconds)
+	return lag_seconds, err
 }
 
 const markFeedFetched = `-- name: MarkFeedFetched :exec
@@ -154,3 +197,42 @@ func (q *Queries) MarkFeedFetched(ctx context.Context, id uuid.UUID) error {
 	_, err := q.db.ExecContext(ctx, markFeedFetched, id)
 	return err
 }
+
+const releaseFeedLease = `-- name: ReleaseFeedLease :exec
+UPDATE feeds
+SET leased_by = NULL, lease_expires_at = NULL
+WHERE id = $1 AND leased_by = $2::text
+`
+
+type ReleaseFeedLeaseParams struct {
+	ID    uuid.UUID
+	Owner string
+}
+
+// Only the holder can release a lease; once it expires someone else may
+// hold it.
+func (q *Queries) ReleaseFeedLease(ctx context.Context, arg ReleaseFeedLeaseParams) error {
+	_, err := q.db.ExecContext(ctx, releaseFeedLease, arg.ID, arg.Owner)
+	return err
+}
+
+const renewFeedLease = `-- name: RenewFeedLease :execrows
+UPDATE feeds
+SET lease_expires_at = NOW() + make_interval(secs => $1::int)
+WHERE id = $2 AND leased_by = $3::text
+`
+
+type RenewFeedLeaseParams struct {
+	LeaseSeconds int32
+	ID           uuid.UUID
+	Owner        string
+}
+
+// Extends a lease that is still held by owner. No rows means it was lost.
+func (q *Queries) RenewFeedLease(ctx context.Context, arg RenewFeedLeaseParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, renewFeedLease, arg.LeaseSeconds, arg.ID, arg.Owner)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -11,14 +11,52 @@ import (
 	"github.com/google/uuid"
 )
 
+type ApiToken struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UserID     uuid.UUID
+	Name       string
+	TokenHash  string
+	LastUsedAt sql.NullTime
+}
+
+type Digest struct {
+	UserID       uuid.UUID
+	CreatedAt    time.Time
+	Email        string
+	LastDigestAt sql.NullTime
+}
+
+type DigestPost struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	PostID    uuid.UUID
+}
+
+type EpisodeDownload struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	UserID      uuid.UUID
+	EnclosureID uuid.UUID
+	Path        string
+	Size        int64
+	Sha256      string
+}
+
 type Feed struct {
-	ID            uuid.UUID
-	CreatedAt     time.Time
-	UpdatedAt     time.Time
-	Name          string
-	Url           string
-	UserID        uuid.UUID
-	LastFetchedAt sql.NullTime
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	Name            string
+	Url             string
+	UserID          uuid.UUID
+	LastFetchedAt   sql.NullTime
+	LastAttemptedAt sql.NullTime
+	Seq             int64
+	LeasedBy        sql.NullString
+	LeaseExpiresAt  sql.NullTime
 }
 
 type FeedFollow struct {
@@ -27,6 +65,37 @@ type FeedFollow struct {
 	UpdatedAt time.Time
 	FeedID    uuid.UUID
 	UserID    uuid.UUID
+	Alias     sql.NullString
+}
+
+type FeverKey struct {
+	UserID    uuid.UUID
+	CreatedAt time.Time
+	KeyHash   string
+}
+
+type FilterRule struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	FeedID    uuid.NullUUID
+	Field     string
+	MatchType string
+	Pattern   string
+	Action    string
+}
+
+type PodcastEpisode struct {
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	PostID          uuid.UUID
+	DurationSeconds sql.NullInt32
+	Season          sql.NullInt32
+	Episode         sql.NullInt32
+	Explicit        sql.NullBool
+	ImageUrl        sql.NullString
 }
 
 type Post struct {
@@ -38,11 +107,100 @@ type Post struct {
 	Description sql.NullString
 	PublishedAt sql.NullTime
 	FeedID      uuid.UUID
+	Author      sql.NullString
+	CommentsUrl sql.NullString
+	Seq         int64
 }
 
-type User struct {
+type PostCategory struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
-	UpdatedAt time.Time
+	PostID    uuid.UUID
 	Name      string
 }
+
+type PostEnclosure struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	PostID    uuid.UUID
+	Url       string
+	Type      sql.NullString
+	Length    sql.NullInt64
+}
+
+type PostState struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	PostID    uuid.UUID
+	Read      bool
+	Starred   bool
+	Hidden    bool
+}
+
+type PostThumbnail struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	PostID    uuid.UUID
+	Url       string
+	Width     sql.NullInt32
+	Height    sql.NullInt32
+}
+
+type Session struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	TokenHash string
+	ExpiresAt time.Time
+}
+
+type User struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Name         string
+	PasswordHash sql.NullString
+}
+
+type Webhook struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	UserID      uuid.UUID
+	Url         string
+	Secret      string
+	FeedID      uuid.NullUUID
+	Category    sql.NullString
+	Keyword     sql.NullString
+	Template    sql.NullString
+	ContentType sql.NullString
+}
+
+type WebhookDelivery struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	WebhookID     uuid.UUID
+	PostID        uuid.UUID
+	Status        string
+	Attempts      int32
+	ResponseCode  sql.NullInt32
+	LastError     sql.NullString
+	NextAttemptAt time.Time
+	DeliveredAt   sql.NullTime
+}
+
+type WebsubSubscription struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	FeedID         uuid.UUID
+	HubUrl         string
+	TopicUrl       string
+	Secret         string
+	State          string
+	LeaseExpiresAt sql.NullTime
+	LastError      sql.NullString
+}
@@ -10,12 +10,13 @@ import (
 	"database/sql"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createPost = `-- name: CreatePost :one
-INSERT INTO posts (title, url, description, published_at, feed_id)
-VALUES ($1, $2, $3, $4, $5)
-RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id
+INSERT INTO posts (title, url, description, published_at, feed_id, author)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id, author, comments_url, seq
 `
 
 type CreatePostParams struct {
@@ -24,6 +25,7 @@ type CreatePostParams struct {
 	Description sql.NullString
 	PublishedAt sql.NullTime
 	FeedID      uuid.UUID
+	Author      sql.NullString
 }
 
 func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, error) {
@@ -33,6 +35,7 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		arg.Description,
 		arg.PublishedAt,
 		arg.FeedID,
+		arg.Author,
 	)
 	var i Post
 	err := row.Scan(
@@ -44,10 +47,88 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		&i.Description,
 		&i.PublishedAt,
 		&i.FeedID,
+		&i.Author,
+		&i.CommentsUrl,
+		&i.Seq,
 	)
 	return i, err
 }
 
+const createPosts = `-- name: CreatePosts :many
+INSERT INTO posts (title, url, description, published_at, feed_id, author, comments_url)
+SELECT
+    t.title,
+    t.url,
+    NULLIF(t.description, ''),
+    NULLIF(t.published_at, '')::timestamp,
+    $1::uuid,
+    NULLIF(t.author, ''),
+    NULLIF(t.comments_url, '')
+FROM unnest(
+    $2::text[],
+    $3::text[],
+    $4::text[],
+    $5::text[],
+    $6::text[],
+    $7::text[]
+) AS t(title, url, description, published_at, author, comments_url)
+ON CONFLICT (url) DO NOTHING
+RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id, author, comments_url, seq
+`
+
+type CreatePostsParams struct {
+	FeedID       uuid.UUID
+	Titles       []string
+	Urls         []string
+	Descriptions []string
+	PublishedAts []string
+	Authors      []string
+	CommentsUrls []string
+}
+
+func (q *Queries) CreatePosts(ctx context.Context, arg CreatePostsParams) ([]Post, error) {
+	rows, err := q.db.QueryContext(ctx, createPosts,
+		arg.FeedID,
+		pq.Array(arg.Titles),
+		pq.Array(arg.Urls),
+		pq.Array(arg.Descriptions),
+		pq.Array(arg.PublishedAts),
+		pq.Array(arg.Authors),
+		pq.Array(arg.CommentsUrls),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Post
+	for rows.Next() {
+		var i Post
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.PublishedAt,
+			&i.FeedID,
+			&i.Author,
+			&i.CommentsUrl,
+			&i.Seq,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getPostsForUser = `-- name: GetPostsForUser :many
 SELECT 
     p.id, 
@@ -55,30 +136,79 @@ SELECT
     p.url, 
     p.description, 
     p.published_at, 
-    p.feed_id
+    p.feed_id,
+    p.author,
+    p.comments_url,
+    COALESCE(ff.alias, f.name) AS feed_name,
+    COALESCE(ps.read, false) AS read,
+    COALESCE(ps.starred, false) AS starred,
+    ARRAY(SELECT pc.name FROM post_categories pc WHERE pc.post_id = p.id ORDER BY pc.name)::text[] AS tags,
+    th.url AS thumbnail_url,
+    th.width AS thumbnail_width,
+    th.height AS thumbnail_height
 FROM posts p
 JOIN feeds f ON p.feed_id = f.id
-WHERE f.user_id = $1
+JOIN feed_follows ff ON ff.feed_id = f.id
+LEFT JOIN post_states ps ON ps.post_id = p.id AND ps.user_id = ff.user_id
+LEFT JOIN LATERAL (
+    SELECT t.url, t.width, t.height FROM post_thumbnails t
+    WHERE t.post_id = p.id
+    ORDER BY t.width DESC NULLS LAST, t.created_at ASC
+    LIMIT 1
+) th ON true
+WHERE ff.user_id = $1
+  AND COALESCE(ps.hidden, false) = false
+  AND ($2::text IS NULL OR EXISTS (
+      SELECT 1 FROM post_categories pc
+      WHERE pc.post_id = p.id AND lower(pc.name) = lower($2)
+  ))
+  AND ($3::text IS NULL OR p.author ILIKE '%' || $3 || '%')
+  AND ($4::uuid IS NULL OR p.feed_id = $4)
+  AND ($5::text IS NULL
+       OR p.title ILIKE '%' || $5 || '%'
+       OR p.description ILIKE '%' || $5 || '%')
 ORDER BY p.published_at DESC
-LIMIT $2
+LIMIT $6 OFFSET $7
 `
 
 type GetPostsForUserParams struct {
-	UserID uuid.UUID
-	Limit  int32
+	UserID    uuid.UUID
+	Tag       sql.NullString
+	Author    sql.NullString
+	FeedID    uuid.NullUUID
+	Search    sql.NullString
+	RowLimit  int32
+	RowOffset int32
 }
 
 type GetPostsForUserRow struct {
-	ID          uuid.UUID
-	Title       string
-	Url         string
-	Description sql.NullString
-	PublishedAt sql.NullTime
-	FeedID      uuid.UUID
+	ID              uuid.UUID
+	Title           string
+	Url             string
+	Description     sql.NullString
+	PublishedAt     sql.NullTime
+	FeedID          uuid.UUID
+	Author          sql.NullString
+	CommentsUrl     sql.NullString
+	FeedName        string
+	Read            bool
+	Starred         bool
+	Tags            []string
+	ThumbnailUrl    sql.NullString
+	ThumbnailWidth  sql.NullInt32
+	ThumbnailHeight sql.NullInt32
 }
 
 func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams) ([]GetPostsForUserRow, error) {
-	rows, err := q.db.QueryContext(ctx, getPostsForUser, arg.UserID, arg.Limit)
+	rows, err := q.db.QueryContext(ctx, getPostsForUser,
+		arg.UserID,
+		arg.Tag,
+		arg.Author,
+		arg.FeedID,
+		arg.Search,
+		arg.RowLimit,
+		arg.RowOffset,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -93,6 +223,15 @@ func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams
 			&i.Description,
 			&i.PublishedAt,
 			&i.FeedID,
+			&i.Author,
+			&i.CommentsUrl,
+			&i.FeedName,
+			&i.Read,
+			&i.Starred,
+			pq.Array(&i.Tags),
+			&i.ThumbnailUrl,
+			&i.ThumbnailWidth,
+			&i.ThumbnailHeight,
 		); err != nil {
 			return nil, err
 		}
@@ -0,0 +1,122 @@
+// Package dbtest gives tests a freshly migrated Postgres schema of their own.
+// Tests that use it are skipped unless GATOR_TEST_DB_URL names a database
+// they may create schemas in.
+package dbtest
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/JadedPigeon/Gator/internal/database"
+	_ "github.com/lib/pq"
+)
+
+// EnvURL is the environment variable holding the test database URL.
+const EnvURL = "GATOR_TEST_DB_URL"
+
+// Open creates an empty schema, applies every migration in sql/schema to it
+// and returns a connection pool that uses it. The schema is dropped when the
+// test ends.
+func Open(t testing.TB) *sql.DB {
+	t.Helper()
+	base := os.Getenv(EnvURL)
+	if base == "" {
+		t.Skipf("%s is not set", EnvURL)
+	}
+	admin, err := sql.Open("postgres", base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Close() })
+
+	suffix := make([]byte, 6)
+	rand.Read(suffix)
+	schema := "gator_test_" + hex.EncodeToString(suffix)
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatalf("error creating test schema: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec("DROP SCHEMA " + schema + " CASCADE"); err != nil {
+			t.Logf("error dropping test schema %s: %v", schema, err)
+		}
+	})
+
+	// lib/pq passes unknown URL parameters on as session settings
+	u, err := url.Parse(base)
+	if err != nil {
+		t.Fatalf("invalid %s: %v", EnvURL, err)
+	}
+	q := u.Query()
+	q.Set("search_path", schema+",public")
+	u.RawQuery = q.Encode()
+	db, err := sql.Open("postgres", u.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	for _, path := range migrations(t) {
+		up, err := upSection(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec(up); err != nil {
+			t.Fatalf("error applying %s: %v", filepath.Base(path), err)
+		}
+	}
+	return db
+}
+
+// Queries is Open wrapped in database.New.
+func Queries(t testing.TB) (*sql.DB, *database.Queries) {
+	t.Helper()
+	db := Open(t)
+	return db, database.New(db)
+}
+
+// migrations lists the goose files in order, found relative to this source
+// file so tests in any package can use them.
+func migrations(t testing.TB) []string {
+	t.Helper()
+	_, file, _, _ := runtime.Caller(0)
+	paths, err := filepath.Glob(filepath.Join(filepath.Dir(file), "..", "..", "sql", "schema", "*.sql"))
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no migrations found: %v", err)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// upSection returns the statements between the "-- +goose Up" and
+// "-- +goose Down" annotations, which goose matches case-insensitively.
+func upSection(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	var up strings.Builder
+	inUp := false
+	for _, line := range strings.Split(string(data), "\n") {
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "-- +goose up":
+			inUp = true
+			continue
+		case "-- +goose down":
+			inUp = false
+			continue
+		}
+		if inUp {
+			up.WriteString(line)
+			up.WriteString("\n")
+		}
+	}
+	return up.String(), nil
+}
@@ -0,0 +1,95 @@
+// Package digest renders the email summary of a user's unread posts.
+package digest
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	"text/template"
+	"time"
+)
+
+type Digest struct {
+	UserName string
+	Feeds    []Feed
+	// Truncated is set when posts were left out to keep the email short.
+	Truncated bool
+}
+
+type Feed struct {
+	Name  string
+	Posts []Post
+}
+
+type Post struct {
+	Title     string
+	URL       string
+	Excerpt   string
+	Published time.Time // zero if the feed gave no date
+}
+
+// Count is the number of posts in the digest.
+func (d Digest) Count() int {
+	n := 0
+	for _, f := range d.Feeds {
+		n += len(f.Posts)
+	}
+	return n
+}
+
+// Subject is the email's subject line.
+func (d Digest) Subject() string {
+	switch {
+	case d.Truncated:
+		return fmt.Sprintf("Gator digest: %d+ new posts", d.Count())
+	case d.Count() == 1:
+		return "Gator digest: 1 new post"
+	}
+	return fmt.Sprintf("Gator digest: %d new posts", d.Count())
+}
+
+var funcs = map[string]any{
+	"date": func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Local().Format("2 Jan 2006 15:04")
+	},
+}
+
+var textTemplate = template.Must(template.New("text").Funcs(funcs).Parse(`Hi {{.UserName}}, here is what's new in the feeds you follow.
+{{range .Feeds}}
+== {{.Name}} ==
+{{range .Posts}}
+* {{.Title}}{{with date .Published}} ({{.}}){{end}}
+  {{.URL}}
+{{- with .Excerpt}}
+  {{.}}
+{{- end}}
+{{end}}{{end}}{{if .Truncated}}
+There are more. Run "gator browse" to see everything.
+{{end}}`))
+
+var htmlTemplate = htmltemplate.Must(htmltemplate.New("html").Funcs(funcs).Parse(`<!DOCTYPE html>
+<html><body style="font-family: sans-serif; max-width: 40em;">
+<p>Hi {{.UserName}}, here is what's new in the feeds you follow.</p>
+{{range .Feeds}}<h2 style="font-size: 1.1em;">{{.Name}}</h2>
+<ul>
+{{range .Posts}}<li style="margin-bottom: 0.8em;"><a href="{{.URL}}">{{.Title}}</a>{{with date .Published}} <small>{{.}}</small>{{end}}
+{{with .Excerpt}}<br><span style="color: #555;">{{.}}</span>{{end}}</li>
+{{end}}</ul>
+{{end}}{{if .Truncated}}<p>There are more. Run <code>gator browse</code> to see everything.</p>
+{{end}}</body></html>
+`))
+
+// Render returns the plain-text and HTML bodies of d.
+func Render(d Digest) (string, string, error) {
+	var text, html strings.Builder
+	if err := textTemplate.Execute(&text, d); err != nil {
+		return "", "", err
+	}
+	if err := htmlTemplate.Execute(&html, d); err != nil {
+		return "", "", err
+	}
+	return text.String(), html.String(), nil
+}
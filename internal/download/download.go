@@ -0,0 +1,149 @@
+// Package download saves media files to disk, resuming interrupted
+// downloads where the server allows it.
+package download
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Client sends download requests; *rss.Fetcher implements it.
+type Client interface {
+	Download(*http.Request) (*http.Response, error)
+}
+
+type Result struct {
+	Path    string
+	Size    int64
+	SHA256  string
+	Resumed bool
+}
+
+// File downloads url to path. Data is written to path + ".part" first and
+// the file is renamed once complete, so an interrupted download is picked
+// up where it stopped by calling File again.
+func File(ctx context.Context, client Client, url, path string) (Result, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return Result{}, fmt.Errorf("error creating download directory: %v", err)
+	}
+	part := path + ".part"
+	var offset int64
+	if info, err := os.Stat(part); err == nil {
+		offset = info.Size()
+	}
+
+	resumed, err := fetch(ctx, client, url, part, offset)
+	if err == errRangeMismatch {
+		// The server answered a different range than the one asked for, so
+		// the part file can't be trusted to line up with it
+		if err := os.Remove(part); err != nil {
+			return Result{}, fmt.Errorf("error removing %s: %v", part, err)
+		}
+		resumed, err = fetch(ctx, client, url, part, 0)
+	}
+	if err != nil {
+		return Result{}, err
+	}
+	return finish(part, path, resumed)
+}
+
+// errRangeMismatch means a 206 response didn't start at the requested offset.
+var errRangeMismatch = errors.New("server sent the wrong range")
+
+// fetch writes url to part, asking for the bytes from offset on if it is
+// positive. It reports whether an earlier download was resumed.
+func fetch(ctx context.Context, client Client, url, part string, offset int64) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return false, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := client.Download(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_WRONLY | os.O_CREATE
+	switch {
+	case resp.StatusCode == http.StatusPartialContent:
+		if rangeStart(resp) != offset {
+			return false, errRangeMismatch
+		}
+		flags |= os.O_APPEND
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		// The part file already holds the whole file
+		return true, nil
+	case resp.StatusCode == http.StatusOK:
+		// No range support, start over
+		flags |= os.O_TRUNC
+		offset = 0
+	default:
+		return false, fmt.Errorf("unexpected HTTP status: %s (%d)", resp.Status, resp.StatusCode)
+	}
+
+	f, err := os.OpenFile(part, flags, 0o644)
+	if err != nil {
+		return false, fmt.Errorf("error opening %s: %v", part, err)
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		return false, fmt.Errorf("download interrupted: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return false, fmt.Errorf("error writing %s: %v", part, err)
+	}
+	return offset > 0, nil
+}
+
+// finish checksums the completed part file and moves it into place.
+func finish(part, path string, resumed bool) (Result, error) {
+	f, err := os.Open(part)
+	if err != nil {
+		return Result{}, err
+	}
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	f.Close()
+	if err != nil {
+		return Result{}, fmt.Errorf("error reading %s: %v", part, err)
+	}
+	if err := os.Rename(part, path); err != nil {
+		return Result{}, fmt.Errorf("error moving download into place: %v", err)
+	}
+	return Result{
+		Path:    path,
+		Size:    size,
+		SHA256:  hex.EncodeToString(h.Sum(nil)),
+		Resumed: resumed,
+	}, nil
+}
+
+// rangeStart returns the first byte of a "Content-Range: bytes a-b/c"
+// header, or -1 if it is missing or malformed.
+func rangeStart(resp *http.Response) int64 {
+	cr, ok := strings.CutPrefix(resp.Header.Get("Content-Range"), "bytes ")
+	if !ok {
+		return -1
+	}
+	start, _, ok := strings.Cut(cr, "-")
+	if !ok {
+		return -1
+	}
+	n, err := strconv.ParseInt(start, 10, 64)
+	if err != nil {
+		return -1
+	}
+	return n
+}
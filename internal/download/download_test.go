@@ -0,0 +1,119 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var episode = bytes.Repeat([]byte("0123456789"), 100)
+
+// httpClient adapts http.Client to Client.
+type httpClient struct{ *http.Client }
+
+func (c httpClient) Download(req *http.Request) (*http.Response, error) { return c.Do(req) }
+
+// rangeServer serves episode, answering range requests with a 206 that
+// starts at shift bytes past the requested offset.
+func rangeServer(t *testing.T, shift int, ranges *[]string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rng := r.Header.Get("Range")
+		*ranges = append(*ranges, rng)
+		if rng == "" {
+			w.Write(episode)
+			return
+		}
+		start, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(rng, "bytes="), "-"))
+		if err != nil {
+			http.Error(w, "bad range", http.StatusBadRequest)
+			return
+		}
+		if start >= len(episode) {
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		start += shift
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, len(episode)-1, len(episode)))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(episode[start:])
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestFileResumes(t *testing.T) {
+	var ranges []string
+	srv := rangeServer(t, 0, &ranges)
+	path := filepath.Join(t.TempDir(), "ep.mp3")
+	if err := os.WriteFile(path+".part", episode[:300], 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := File(context.Background(), httpClient{srv.Client()}, srv.URL, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Resumed || res.Size != int64(len(episode)) {
+		t.Errorf("got %+v, want a resumed download of %d bytes", res, len(episode))
+	}
+	if len(ranges) != 1 || ranges[0] != "bytes=300-" {
+		t.Errorf("requests sent ranges %q, want one for bytes=300-", ranges)
+	}
+	checkFile(t, path)
+}
+
+func TestFileRestartsOnRangeMismatch(t *testing.T) {
+	var ranges []string
+	srv := rangeServer(t, 100, &ranges)
+	path := filepath.Join(t.TempDir(), "ep.mp3")
+	if err := os.WriteFile(path+".part", episode[:300], 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := File(context.Background(), httpClient{srv.Client()}, srv.URL, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Resumed {
+		t.Error("download reported as resumed after the part file was discarded")
+	}
+	if len(ranges) != 2 || ranges[1] != "" {
+		t.Errorf("requests sent ranges %q, want a resume and then a full request", ranges)
+	}
+	checkFile(t, path)
+}
+
+func TestFileAlreadyComplete(t *testing.T) {
+	var ranges []string
+	srv := rangeServer(t, 0, &ranges)
+	path := filepath.Join(t.TempDir(), "ep.mp3")
+	if err := os.WriteFile(path+".part", episode, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := File(context.Background(), httpClient{srv.Client()}, srv.URL, path); err != nil {
+		t.Fatal(err)
+	}
+	checkFile(t, path)
+}
+
+func checkFile(t *testing.T, path string) {
+	t.Helper()
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, episode) {
+		t.Errorf("downloaded %d bytes that don't match the episode", len(got))
+	}
+	if _, err := os.Stat(path + ".part"); !os.IsNotExist(err) {
+		t.Error("part file left behind")
+	}
+}
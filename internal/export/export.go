@@ -0,0 +1,104 @@
+// Package export renders a user's merged timeline as a feed other readers
+// can subscribe to.
+package export
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/JadedPigeon/Gator/internal/database"
+	"github.com/JadedPigeon/Gator/internal/rss"
+	"github.com/google/uuid"
+)
+
+type Format string
+
+const (
+	FormatRSS  Format = "rss"
+	FormatAtom Format = "atom"
+)
+
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case FormatRSS, FormatAtom:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown feed format %q (expected rss or atom)", s)
+}
+
+// ContentType is the media type to serve a format with.
+func (f Format) ContentType() string {
+	if f == FormatAtom {
+		return "application/atom+xml; charset=utf-8"
+	}
+	return "application/rss+xml; charset=utf-8"
+}
+
+type Options struct {
+	Format Format
+	// Tag limits the feed to posts in one category.
+	Tag   string
+	Limit int
+	// Link is the page the feed points readers to; SelfURL is where the
+	// feed itself is served, if anywhere.
+	Link    string
+	SelfURL string
+}
+
+// Write renders the user's latest posts, newest first.
+func Write(ctx context.Context, w io.Writer, db *database.Queries, user database.User, opts Options) error {
+	posts, err := db.GetPostsForUser(ctx, database.GetPostsForUserParams{
+		UserID:   user.ID,
+		Tag:      sqlString(opts.Tag),
+		RowLimit: int32(opts.Limit),
+	})
+	if err != nil {
+		return fmt.Errorf("error retrieving posts: %v", err)
+	}
+
+	title := "Gator: " + user.Name
+	if opts.Tag != "" {
+		title += " – " + opts.Tag
+	}
+	doc := rss.Document{
+		// Derived from the user and tag so it stays the same across exports
+		ID:          "urn:uuid:" + uuid.NewSHA1(user.ID, []byte(opts.Tag)).String(),
+		Title:       title,
+		Link:        opts.Link,
+		SelfURL:     opts.SelfURL,
+		Description: "Posts from the feeds " + user.Name + " follows in Gator",
+	}
+	for _, p := range posts {
+		entry := rss.Entry{
+			ID:          "urn:uuid:" + p.ID.String(),
+			Title:       p.Title,
+			Link:        p.Url,
+			Description: p.Description.String,
+			Author:      p.Author.String,
+		}
+		if p.PublishedAt.Valid {
+			entry.Published = p.PublishedAt.Time
+			if entry.Published.After(doc.Updated) {
+				doc.Updated = entry.Published
+			}
+		}
+		entry.Categories = p.Tags
+		doc.Entries = append(doc.Entries, entry)
+	}
+	if doc.Updated.IsZero() {
+		doc.Updated = time.Now()
+	}
+
+	if opts.Format == FormatAtom {
+		return rss.WriteAtom(w, doc)
+	}
+	return rss.WriteRSS(w, doc)
+}
+
+func sqlString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -0,0 +1,70 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/JadedPigeon/Gator/internal/database"
+	"github.com/JadedPigeon/Gator/internal/dbtest"
+	"github.com/JadedPigeon/Gator/internal/rss"
+	"github.com/google/uuid"
+)
+
+func TestWriteRoundTrip(t *testing.T) {
+	_, db := dbtest.Queries(t)
+	ctx := context.Background()
+	now := time.Now()
+	user, err := db.CreateUser(ctx, database.CreateUserParams{ID: uuid.New(), CreatedAt: now, UpdatedAt: now, Name: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	feed, err := db.CreateFeed(ctx, database.CreateFeedParams{Name: "Blog", Url: "https://example.com/feed", UserID: user.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.CreateFeedFollow(ctx, database.CreateFeedFollowParams{FeedID: feed.ID, UserID: user.ID}); err != nil {
+		t.Fatal(err)
+	}
+	posts, err := db.CreatePosts(ctx, database.CreatePostsParams{
+		FeedID:       feed.ID,
+		Titles:       []string{"Hello"},
+		Urls:         []string{"https://example.com/hello"},
+		Descriptions: []string{"<p>Hi</p>"},
+		PublishedAts: []string{"2024-03-01 09:30:00"},
+		Authors:      []string{"Jane Doe"},
+		CommentsUrls: []string{""},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A category with a comma in it must stay one category
+	err = db.CreatePostCategories(ctx, database.CreatePostCategoriesParams{
+		PostIds: []uuid.UUID{posts[0].ID, posts[0].ID},
+		Names:   []string{"Tips, Tricks", "Go"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := Write(ctx, &buf, db, user, Options{Format: FormatRSS, Limit: 10, Link: "https://gator.example.com/"}); err != nil {
+		t.Fatal(err)
+	}
+	out, err := rss.Parse(&buf)
+	if err != nil {
+		t.Fatalf("reading back the export: %v", err)
+	}
+	if len(out.Channel.Item) != 1 {
+		t.Fatalf("got %d items, want 1", len(out.Channel.Item))
+	}
+	item := out.Channel.Item[0]
+	if item.Title != "Hello" || item.Link != "https://example.com/hello" || item.Description != "<p>Hi</p>" || item.Author != "Jane Doe" {
+		t.Errorf("item = %q, %q, %q, %q", item.Title, item.Link, item.Description, item.Author)
+	}
+	if want := []string{"Go", "Tips, Tricks"}; !reflect.DeepEqual(item.Categories, want) {
+		t.Errorf("categories = %q, want %q", item.Categories, want)
+	}
+}
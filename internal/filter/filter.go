@@ -0,0 +1,133 @@
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	FieldTitle       = "title"
+	FieldDescription = "description"
+	FieldURL         = "url"
+	FieldAuthor      = "author"
+
+	MatchSubstring = "substring"
+	MatchRegex     = "regex"
+
+	ActionHide     = "hide"
+	ActionMarkRead = "mark-read"
+	ActionStar     = "star"
+	// ActionAlert runs the alert command when agg stores a matching post.
+	ActionAlert = "alert"
+)
+
+// Item is the part of a post that rules can look at.
+type Item struct {
+	FeedID      uuid.UUID
+	Title       string
+	Description string
+	URL         string
+	Author      string
+}
+
+type Rule struct {
+	ID      uuid.UUID
+	UserID  uuid.UUID
+	FeedID  uuid.NullUUID
+	Field   string
+	Match   string
+	Pattern string
+	Action  string
+
+	re *regexp.Regexp
+}
+
+// Result collects the actions of every rule that matched an item.
+type Result struct {
+	Hide     bool
+	MarkRead bool
+	Star     bool
+	Alert    bool
+}
+
+// Any reports whether the result changes the post's state. Alerts don't.
+func (r Result) Any() bool {
+	return r.Hide || r.MarkRead || r.Star
+}
+
+// Compile validates a rule and prepares its pattern for matching.
+func Compile(r Rule) (Rule, error) {
+	switch r.Field {
+	case FieldTitle, FieldDescription, FieldURL, FieldAuthor:
+	default:
+		return Rule{}, fmt.Errorf("unknown field %q (expected title, description, url or author)", r.Field)
+	}
+	switch r.Action {
+	case ActionHide, ActionMarkRead, ActionStar, ActionAlert:
+	default:
+		return Rule{}, fmt.Errorf("unknown action %q (expected hide, mark-read, star or alert)", r.Action)
+	}
+	if r.Pattern == "" {
+		return Rule{}, fmt.Errorf("pattern must not be empty")
+	}
+	switch r.Match {
+	case MatchSubstring:
+		r.re = nil
+	case MatchRegex:
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return Rule{}, fmt.Errorf("invalid regex %q: %v", r.Pattern, err)
+		}
+		r.re = re
+	default:
+		return Rule{}, fmt.Errorf("unknown match type %q (expected substring or regex)", r.Match)
+	}
+	return r, nil
+}
+
+// Matches reports whether the rule applies to the item. Substring matches are
+// case-insensitive; regex rules can opt into that with (?i).
+func (r Rule) Matches(it Item) bool {
+	if r.FeedID.Valid && r.FeedID.UUID != it.FeedID {
+		return false
+	}
+	var value string
+	switch r.Field {
+	case FieldTitle:
+		value = it.Title
+	case FieldDescription:
+		value = it.Description
+	case FieldURL:
+		value = it.URL
+	case FieldAuthor:
+		value = it.Author
+	}
+	if r.re != nil {
+		return r.re.MatchString(value)
+	}
+	return strings.Contains(strings.ToLower(value), strings.ToLower(r.Pattern))
+}
+
+// Apply runs every rule against the item and merges their actions.
+func Apply(rules []Rule, it Item) Result {
+	var res Result
+	for _, r := range rules {
+		if !r.Matches(it) {
+			continue
+		}
+		switch r.Action {
+		case ActionHide:
+			res.Hide = true
+		case ActionMarkRead:
+			res.MarkRead = true
+		case ActionStar:
+			res.Star = true
+		case ActionAlert:
+			res.Alert = true
+		}
+	}
+	return res
+}
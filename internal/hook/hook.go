@@ -0,0 +1,99 @@
+// Package hook runs the user's alert command for posts that match an
+// alert rule, e.g. to call notify-send or update a status bar.
+package hook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/JadedPigeon/Gator/internal/webhook"
+)
+
+const (
+	EventAlert = "alert"
+	// maxOutput caps how much of the command's output is logged.
+	maxOutput = 4 << 10
+)
+
+// timeout stops a stuck command from holding up agg. Tests shorten it.
+var timeout = 30 * time.Second
+
+// Alert is sent to the command as JSON on stdin: the same fields as a
+// webhook payload, plus the user the rule belongs to.
+type Alert struct {
+	User string `json:"user"`
+	webhook.Payload
+}
+
+// Env returns the alert's fields as GATOR_* environment variables. The
+// description is left out, since it can be long; it is in the JSON.
+func (a Alert) Env() []string {
+	published := ""
+	if a.Post.PublishedAt != nil {
+		published = a.Post.PublishedAt.Format(time.RFC3339)
+	}
+	return []string{
+		"GATOR_EVENT=" + a.Event,
+		"GATOR_USER=" + a.User,
+		"GATOR_FEED_NAME=" + a.Feed.Name,
+		"GATOR_FEED_URL=" + a.Feed.URL,
+		"GATOR_POST_ID=" + a.Post.ID,
+		"GATOR_POST_TITLE=" + a.Post.Title,
+		"GATOR_POST_URL=" + a.Post.URL,
+		"GATOR_POST_AUTHOR=" + a.Post.Author,
+		"GATOR_POST_PUBLISHED_AT=" + published,
+	}
+}
+
+// Run runs command with sh -c, passing the alert in the environment and
+// on stdin. The command's output is logged at debug level rather than
+// mixed into Gator's; its errors still go to Gator's stderr.
+func Run(ctx context.Context, log *slog.Logger, command string, a Alert) error {
+	input, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Env = append(os.Environ(), a.Env()...)
+	cmd.Stdin = bytes.NewReader(input)
+	out := &limitedBuffer{n: maxOutput}
+	cmd.Stdout = out
+	cmd.Stderr = os.Stderr
+	// Don't wait on background processes that inherited stdin
+	cmd.WaitDelay = time.Second
+	err = cmd.Run()
+	if out.Len() > 0 {
+		log.Debug("Alert command output", "post_id", a.Post.ID, "output", strings.TrimSpace(out.String()))
+	}
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("alert command timed out after %s", timeout)
+		}
+		return fmt.Errorf("alert command failed: %v", err)
+	}
+	return nil
+}
+
+// limitedBuffer keeps the first n bytes written to it and drops the rest,
+// so a chatty command can't use up memory.
+type limitedBuffer struct {
+	bytes.Buffer
+	n int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.n - b.Len(); room > 0 {
+		b.Buffer.Write(p[:min(len(p), room)])
+	}
+	return len(p), nil
+}
@@ -0,0 +1,122 @@
+package hook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JadedPigeon/Gator/internal/webhook"
+)
+
+func testAlert() Alert {
+	published := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	return Alert{
+		User: "alice",
+		Payload: webhook.Payload{
+			Event: EventAlert,
+			Feed:  webhook.Feed{Name: "Blog", URL: "https://example.com/feed.xml"},
+			Post: webhook.Post{
+				ID:          "7d7ba9a2-4f0e-4b8e-9a53-3f2d1c1e0b6a",
+				Title:       `Rock & "roll"; $(rm -rf /)`,
+				URL:         "https://example.com/rock",
+				Description: "<p>Loud</p>",
+				Author:      "Jane Doe",
+				PublishedAt: &published,
+			},
+		},
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("GATOR_TEST_DIR", dir)
+	var logs bytes.Buffer
+	log := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	a := testAlert()
+
+	err := Run(context.Background(), log, `cat > "$GATOR_TEST_DIR/out"; env > "$GATOR_TEST_DIR/env"; echo notified`, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Alert
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("stdin is not JSON: %v: %s", err, data)
+	}
+	if got.User != a.User || got.Event != a.Event || got.Feed != a.Feed || got.Post.Title != a.Post.Title ||
+		got.Post.Description != a.Post.Description || !got.Post.PublishedAt.Equal(*a.Post.PublishedAt) {
+		t.Errorf("stdin = %+v, want %+v", got, a)
+	}
+
+	data, err = os.ReadFile(filepath.Join(dir, "env"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := strings.Split(string(data), "\n")
+	for _, want := range []string{
+		"GATOR_EVENT=alert",
+		"GATOR_USER=alice",
+		"GATOR_FEED_NAME=Blog",
+		"GATOR_FEED_URL=https://example.com/feed.xml",
+		"GATOR_POST_ID=7d7ba9a2-4f0e-4b8e-9a53-3f2d1c1e0b6a",
+		`GATOR_POST_TITLE=Rock & "roll"; $(rm -rf /)`,
+		"GATOR_POST_URL=https://example.com/rock",
+		"GATOR_POST_AUTHOR=Jane Doe",
+		"GATOR_POST_PUBLISHED_AT=2024-03-01T09:30:00Z",
+	} {
+		if !contains(env, want) {
+			t.Errorf("environment is missing %s", want)
+		}
+	}
+	for _, v := range env {
+		if strings.HasPrefix(v, "GATOR_POST_DESCRIPTION=") {
+			t.Errorf("environment has the description: %s", v)
+		}
+	}
+
+	if !strings.Contains(logs.String(), "level=DEBUG") || !strings.Contains(logs.String(), "output=notified") {
+		t.Errorf("command output was not logged at debug level: %s", logs.String())
+	}
+}
+
+func contains(lines []string, s string) bool {
+	for _, line := range lines {
+		if line == s {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRunFailure(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	err := Run(context.Background(), log, "exit 3", testAlert())
+	if err == nil || !strings.Contains(err.Error(), "exit status 3") {
+		t.Errorf("got %v, want the exit status", err)
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	defer func(d time.Duration) { timeout = d }(timeout)
+	timeout = 100 * time.Millisecond
+	log := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	start := time.Now()
+	err := Run(context.Background(), log, "exec sleep 10", testAlert())
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("got %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Run took %v to give up", elapsed)
+	}
+}
@@ -0,0 +1,201 @@
+// Package mail builds plain-text + HTML emails and sends them over SMTP.
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	netmail "net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPort = 587
+	dialTimeout = 30 * time.Second
+)
+
+// Config describes the SMTP server to send through.
+type Config struct {
+	Host     string
+	Port     int // default 587
+	Username string
+	Password string
+	// ImplicitTLS connects with TLS from the start, as port 465 expects.
+	// Otherwise STARTTLS is used whenever the server offers it.
+	ImplicitTLS bool
+}
+
+// Message is an email with a plain-text body and an HTML alternative.
+type Message struct {
+	From    string
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+	Date    time.Time
+}
+
+// Bytes renders m as a multipart/alternative message. Mail clients show
+// the last part they can display, so HTML goes after the text.
+func (m *Message) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+
+	date := m.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+	header := []struct{ name, value string }{
+		{"From", m.From},
+		{"To", m.To},
+		{"Subject", mime.QEncoding.Encode("utf-8", m.Subject)},
+		{"Date", date.Format(time.RFC1123Z)},
+		{"Message-ID", messageID(m.From)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", `multipart/alternative; boundary="` + w.Boundary() + `"`},
+	}
+	var head bytes.Buffer
+	for _, h := range header {
+		if strings.ContainsAny(h.value, "\r\n") {
+			return nil, fmt.Errorf("invalid %s header", h.name)
+		}
+		fmt.Fprintf(&head, "%s: %s\r\n", h.name, h.value)
+	}
+	head.WriteString("\r\n")
+
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", m.Text},
+		{"text/html; charset=utf-8", m.HTML},
+	} {
+		pw, err := w.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(pw)
+		if _, err := qp.Write([]byte(part.body)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return append(head.Bytes(), buf.Bytes()...), nil
+}
+
+// messageID makes a unique Message-ID in the sender's domain.
+func messageID(from string) string {
+	domain := "localhost"
+	if at := strings.LastIndex(from, "@"); at >= 0 {
+		domain = strings.Trim(from[at+1:], "> ")
+	}
+	b := make([]byte, 16)
+	rand.Read(b)
+	return "<" + hex.EncodeToString(b) + "@" + domain + ">"
+}
+
+// Send delivers m through the server in cfg.
+func Send(ctx context.Context, cfg Config, m *Message) error {
+	if cfg.Host == "" {
+		return errors.New("no SMTP host configured")
+	}
+	from, err := envelopeAddress(m.From)
+	if err != nil {
+		return fmt.Errorf("invalid sender: %v", err)
+	}
+	to, err := envelopeAddress(m.To)
+	if err != nil {
+		return fmt.Errorf("invalid recipient: %v", err)
+	}
+	body, err := m.Bytes()
+	if err != nil {
+		return err
+	}
+
+	port := cfg.Port
+	if port == 0 {
+		port = defaultPort
+	}
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(port))
+	tlsConfig := &tls.Config{ServerName: cfg.Host}
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	var conn net.Conn
+	if cfg.ImplicitTLS {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("error connecting to SMTP server: %v", err)
+	}
+	// net/smtp has no context support, so the deadline stands in for it
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	c, err := smtp.NewClient(conn, cfg.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("error starting SMTP session: %v", err)
+	}
+	defer c.Close()
+	if !cfg.ImplicitTLS {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err := c.StartTLS(tlsConfig); err != nil {
+				return fmt.Errorf("error starting TLS: %v", err)
+			}
+		}
+	}
+	if cfg.Username != "" {
+		// PlainAuth refuses to send the password unencrypted, except to
+		// localhost
+		if err := c.Auth(smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)); err != nil {
+			return fmt.Errorf("error authenticating: %v", err)
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return fmt.Errorf("error sending mail: %v", err)
+	}
+	if err := c.Rcpt(to); err != nil {
+		return fmt.Errorf("error sending mail: %v", err)
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("error sending mail: %v", err)
+	}
+	if _, err := w.Write(body); err != nil {
+		return fmt.Errorf("error sending mail: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("error sending mail: %v", err)
+	}
+	return c.Quit()
+}
+
+// envelopeAddress extracts the bare address from "Name <addr>".
+func envelopeAddress(s string) (string, error) {
+	addr, err := netmail.ParseAddress(s)
+	if err != nil {
+		return "", err
+	}
+	return addr.Address, nil
+}
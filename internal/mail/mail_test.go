@@ -0,0 +1,190 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"io"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// smtpServer is a local stand-in for a mail server. It speaks just enough
+// SMTP for Send, without TLS, and records each session.
+type smtpServer struct {
+	addr string
+	port int
+
+	mu       sync.Mutex
+	commands []string
+	auth     string
+	data     string
+}
+
+func newSMTPServer(t *testing.T, stall bool) *smtpServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	srv := &smtpServer{addr: ln.Addr().String(), port: ln.Addr().(*net.TCPAddr).Port}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go srv.serve(conn, stall)
+		}
+	}()
+	return srv
+}
+
+func (srv *smtpServer) serve(conn net.Conn, stall bool) {
+	defer conn.Close()
+	if stall {
+		// Accept the connection but never greet
+		io.Copy(io.Discard, conn)
+		return
+	}
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 localhost test server")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		verb = strings.ToUpper(verb)
+		srv.mu.Lock()
+		srv.commands = append(srv.commands, verb)
+		srv.mu.Unlock()
+		switch verb {
+		case "EHLO":
+			tp.PrintfLine("250-localhost")
+			tp.PrintfLine("250 AUTH PLAIN")
+		case "AUTH":
+			_, initial, _ := strings.Cut(arg, " ")
+			creds, _ := base64.StdEncoding.DecodeString(initial)
+			srv.mu.Lock()
+			srv.auth = string(creds)
+			srv.mu.Unlock()
+			tp.PrintfLine("235 ok")
+		case "MAIL", "RCPT":
+			srv.mu.Lock()
+			srv.commands[len(srv.commands)-1] = line
+			srv.mu.Unlock()
+			tp.PrintfLine("250 ok")
+		case "DATA":
+			tp.PrintfLine("354 go ahead")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			srv.mu.Lock()
+			srv.data = string(data)
+			srv.mu.Unlock()
+			tp.PrintfLine("250 queued")
+		case "QUIT":
+			tp.PrintfLine("221 bye")
+			return
+		default:
+			tp.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+func TestSend(t *testing.T) {
+	srv := newSMTPServer(t, false)
+	msg := &Message{
+		From:    "Gator <gator@example.com>",
+		To:      "alice@example.com",
+		Subject: "3 new posts – digest",
+		Text:    "Hello, world",
+		HTML:    "<p>Hello, world</p>",
+		Date:    time.Date(2024, 3, 9, 14, 30, 0, 0, time.UTC),
+	}
+	// Plain auth is allowed without TLS only to localhost
+	cfg := Config{Host: "localhost", Port: srv.port, Username: "gator", Password: "s3cret"}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := Send(ctx, cfg, msg); err != nil {
+		t.Fatal(err)
+	}
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	want := []string{"EHLO", "AUTH", "MAIL FROM:<gator@example.com>", "RCPT TO:<alice@example.com>", "DATA", "QUIT"}
+	if strings.Join(srv.commands, "|") != strings.Join(want, "|") {
+		t.Errorf("commands = %q, want %q", srv.commands, want)
+	}
+	if srv.auth != "\x00gator\x00s3cret" {
+		t.Errorf("AUTH PLAIN credentials = %q", srv.auth)
+	}
+	// ReadDotBytes has turned the CRLF line endings into LF
+	for _, s := range []string{
+		"From: Gator <gator@example.com>\n",
+		"To: alice@example.com\n",
+		"Subject: =?utf-8?q?3_new_posts_=E2=80=93_digest?=\n",
+		"Date: Sat, 09 Mar 2024 14:30:00 +0000\n",
+		"Content-Type: multipart/alternative;",
+		"Hello, world",
+		"<p>Hello, world</p>",
+	} {
+		if !strings.Contains(srv.data, s) {
+			t.Errorf("message lacks %q:\n%s", s, srv.data)
+		}
+	}
+	// The HTML part must come last, so clients prefer it
+	if strings.Index(srv.data, "text/plain") > strings.Index(srv.data, "text/html") {
+		t.Error("HTML part comes before the text part")
+	}
+}
+
+func TestSendStalledServer(t *testing.T) {
+	srv := newSMTPServer(t, true)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := Send(ctx, Config{Host: "127.0.0.1", Port: srv.port}, &Message{From: "a@example.com", To: "b@example.com"})
+	if err == nil {
+		t.Fatal("sending to a server that never answers succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Send took %v, want about the 100ms deadline", elapsed)
+	}
+}
+
+func TestSendRejectsBadAddresses(t *testing.T) {
+	for _, m := range []*Message{
+		{From: "not an address", To: "b@example.com"},
+		{From: "a@example.com", To: "b@example.com\r\nBcc: c@example.com"},
+	} {
+		if err := Send(context.Background(), Config{Host: "localhost", Port: 1}, m); err == nil {
+			t.Errorf("Send(%q -> %q) succeeded", m.From, m.To)
+		}
+	}
+}
+
+func TestBytesRejectsHeaderInjection(t *testing.T) {
+	m := &Message{From: "a@example.com", To: "b@example.com", Subject: "hi\r\nBcc: c@example.com"}
+	// Bytes may refuse the subject, or encode it so the line break stays
+	// out of the headers
+	b, err := m.Bytes()
+	if err != nil {
+		return
+	}
+	r := textproto.NewReader(bufio.NewReader(strings.NewReader(string(b))))
+	header, err := r.ReadMIMEHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Get("Bcc") != "" {
+		t.Errorf("subject injected a Bcc header: %q", header.Get("Bcc"))
+	}
+}
@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// TestRegistryWrite pins the exposition format: labelled counters sorted by
+// label values with quotes, backslashes and newlines escaped, unlabelled
+// counters reporting 0 before any increment, cumulative histogram buckets
+// ending in +Inf, and a failing GaugeFunc written without a sample.
+func TestRegistryWrite(t *testing.T) {
+	reg := NewRegistry()
+	fetches := reg.Counter("gator_fetches_total", "Feed fetches, by result.", "result", "feed")
+	posts := reg.Counter("gator_posts_total", "Posts stored.")
+	reg.Counter("gator_errors_total", "Errors.")
+	duration := reg.Histogram("gator_fetch_duration_seconds", "How long fetches take.", []float64{0.5, 1, 5})
+	reg.GaugeFunc("gator_feeds", "Feeds\nfollowed, with a \\.", func(context.Context) (float64, error) {
+		return 3, nil
+	})
+	reg.GaugeFunc("gator_broken", "Always fails.", func(context.Context) (float64, error) {
+		return 0, errors.New("database is down")
+	})
+
+	fetches.Inc("ok", "a\"b\\c\nd")
+	fetches.Add(2, "error", "x")
+	posts.Add(5)
+	// A value equal to a bound goes in that bound's bucket
+	duration.Observe(0.5)
+	duration.Observe(0.25)
+	duration.Observe(1)
+	duration.Observe(7)
+
+	var buf bytes.Buffer
+	if err := reg.Write(context.Background(), &buf); err != nil {
+		t.Fatal(err)
+	}
+	golden := filepath.Join("testdata", "registry.golden")
+	if *update {
+		if err := os.WriteFile(golden, buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("reading golden file (run with -update to create it): %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("output differs from %s\ngot:\n%s\nwant:\n%s", golden, buf.Bytes(), want)
+	}
+}
@@ -0,0 +1,73 @@
+package rss
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// windows1252High maps bytes 0x80-0x9F of Windows-1252 to Unicode. Undefined
+// bytes map to themselves, as browsers do.
+var windows1252High = [32]rune{
+	'€', '\u0081', '‚', 'ƒ', '„', '…', '†', '‡',
+	'ˆ', '‰', 'Š', '‹', 'Œ', '\u008D', 'Ž', '\u008F',
+	'\u0090', '‘', '’', '“', '”', '•', '–', '—',
+	'˜', '™', 'š', '›', 'œ', '\u009D', 'ž', 'Ÿ',
+}
+
+// charsetReader is used as xml.Decoder.CharsetReader for feeds whose XML
+// declaration names an encoding other than UTF-8.
+func charsetReader(label string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(strings.TrimSpace(label)) {
+	case "utf-8", "utf8", "us-ascii", "ascii":
+		return input, nil
+	case "iso-8859-1", "iso8859-1", "latin1", "l1",
+		"windows-1252", "cp1252", "x-cp1252":
+		// Like browsers, treat ISO-8859-1 as Windows-1252: feeds labelled
+		// latin1 routinely contain curly quotes from the 0x80-0x9F range.
+		return &singleByteReader{r: bufio.NewReader(input)}, nil
+	}
+	return nil, fmt.Errorf("unsupported charset %q", label)
+}
+
+// singleByteReader decodes Windows-1252 into UTF-8.
+type singleByteReader struct {
+	r       *bufio.Reader
+	pending []byte
+}
+
+func (d *singleByteReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(d.pending) > 0 {
+			c := copy(p[n:], d.pending)
+			d.pending = d.pending[c:]
+			n += c
+			continue
+		}
+		if n > 0 && d.r.Buffered() == 0 {
+			// Don't block for more input once something has been decoded
+			break
+		}
+		b, err := d.r.ReadByte()
+		if err != nil {
+			if n > 0 {
+				return n, nil
+			}
+			return 0, err
+		}
+		r := rune(b)
+		if b >= 0x80 && b <= 0x9F {
+			r = windows1252High[b-0x80]
+		}
+		if r < utf8.RuneSelf {
+			p[n] = byte(r)
+			n++
+			continue
+		}
+		d.pending = utf8.AppendRune(d.pending[:0], r)
+	}
+	return n, nil
+}
@@ -3,56 +3,235 @@ package rss
 import (
 	"context"
 	"encoding/xml"
+	"errors"
 	"fmt"
-	"html"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// defaultTimeout caps a fetch even when the caller's context has no deadline.
+const defaultTimeout = 60 * time.Second
+
 type RSSFeed struct {
-	Channel struct {
-		Title       string    `xml:"title"`
-		Link        string    `xml:"link"`
-		Description string    `xml:"description"`
-		Item        []RSSItem `xml:"item"`
-	} `xml:"channel"`
+	Channel RSSChannel `xml:"channel"`
+}
+
+type RSSChannel struct {
+	Title       string    `xml:"title"`
+	Link        string    `xml:"link"`
+	Description string    `xml:"description"`
+	Item        []RSSItem `xml:"item"`
+	// Hub and Self come from <atom:link rel="hub"> and rel="self", or from
+	// the response's Link header. A feed with a hub supports WebSub.
+	Hub  string `xml:"-"`
+	Self string `xml:"-"`
 }
 
 type RSSItem struct {
-	Title       string `xml:"title"`
-	Link        string `xml:"link"`
-	Description string `xml:"description"`
-	PubDate     string `xml:"pubDate"`
+	// The namespaced fields come first because encoding/xml gives an
+	// element to the first field that matches, and Title, Link and
+	// Description match their tag in any namespace. Otherwise itunes:title
+	// or media:description would overwrite the RSS values, and an empty
+	// atom:link would wipe out <link>.
+	ITunesTitle      string     `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd title"`
+	MediaTitle       string     `xml:"http://search.yahoo.com/mrss/ title"`
+	MediaDescription string     `xml:"http://search.yahoo.com/mrss/ description"`
+	AtomLinks        []AtomLink `xml:"http://www.w3.org/2005/Atom link"`
+	Title            string     `xml:"title"`
+	Link             string     `xml:"link"`
+	Description      string     `xml:"description"`
+	PubDate          string     `xml:"pubDate"`
+	// Author falls back to dc:creator, which most blogs use instead
+	Author     string         `xml:"author"`
+	Creator    string         `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Categories []string       `xml:"category"`
+	Comments   string         `xml:"comments"`
+	Enclosures []RSSEnclosure `xml:"enclosure"`
+
+	// Podcast metadata from the iTunes namespace; see podcast.go
+	ITunesDuration string      `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd duration"`
+	ITunesSeason   string      `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd season"`
+	ITunesEpisode  string      `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd episode"`
+	ITunesExplicit string      `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd explicit"`
+	ITunesImage    ITunesImage `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd image"`
+
+	// Media RSS; see media.go
+	MediaThumbnails []MediaThumbnail `xml:"http://search.yahoo.com/mrss/ thumbnail"`
+	MediaContents   []MediaContent   `xml:"http://search.yahoo.com/mrss/ content"`
+	MediaGroups     []MediaGroup     `xml:"http://search.yahoo.com/mrss/ group"`
+}
+
+// AtomLink is an <atom:link> inside an item.
+type AtomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+}
+
+type RSSEnclosure struct {
+	URL  string `xml:"url,attr"`
+	Type string `xml:"type,attr"`
+	// Length is kept as text because feeds often get it wrong; see Size
+	Length string `xml:"length,attr"`
+}
+
+// Size returns the enclosure's length in bytes, or 0 if it is missing or
+// invalid.
+func (e RSSEnclosure) Size() int64 {
+	n, err := strconv.ParseInt(strings.TrimSpace(e.Length), 10, 64)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
+// HTTPError is returned when a feed responds with a status other than 200.
+type HTTPError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("unexpected HTTP status: %s (%d)", e.Status, e.StatusCode)
+}
+
+// FetchInfo describes one feed fetch; see FetcherConfig.OnFetch.
+type FetchInfo struct {
+	URL      string
+	Status   int // 0 if no response arrived
+	Duration time.Duration
+	Bytes    int64 // as read off the wire, before decompression
+	Items    int
+	Err      error
+}
+
+// ParseFailed reports whether the feed arrived but wasn't valid XML.
+func (i FetchInfo) ParseFailed() bool {
+	var syntaxErr *xml.SyntaxError
+	return errors.As(i.Err, &syntaxErr)
 }
 
+// countingBody counts the bytes read from a response body.
+type countingBody struct {
+	io.ReadCloser
+	n int64
+}
+
+func (c *countingBody) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// FetchFeed fetches a feed with the default Fetcher.
 func FetchFeed(ctx context.Context, feedURL string) (*RSSFeed, error) {
+	return DefaultFetcher().Fetch(ctx, feedURL)
+}
+
+// Fetch downloads and parses a whole feed.
+func (f *Fetcher) Fetch(ctx context.Context, feedURL string) (*RSSFeed, error) {
+	var items []RSSItem
+	channel, err := f.FetchStream(ctx, feedURL, func(item RSSItem) error {
+		items = append(items, item)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	channel.Item = items
+	return &RSSFeed{Channel: *channel}, nil
+}
+
+// FetchStream downloads a feed and hands each item to fn while the body is
+// still being read; see Stream.
+func (f *Fetcher) FetchStream(ctx context.Context, feedURL string, fn func(RSSItem) error) (channel *RSSChannel, err error) {
+	start := time.Now()
+	info := FetchInfo{URL: feedURL}
+	wire := &countingBody{}
+	defer func() {
+		info.Duration = time.Since(start)
+		info.Bytes = wire.n
+		info.Err = err
+		attrs := []any{"feed_url", feedURL, "status", info.Status, "duration", info.Duration,
+			"bytes", info.Bytes, "items", info.Items}
+		if err != nil {
+			f.log.DebugContext(ctx, "Feed fetch failed", append(attrs, "error", err)...)
+		} else {
+			f.log.DebugContext(ctx, "Fetched feed", attrs...)
+		}
+		if f.onFetch != nil {
+			f.onFetch(info)
+		}
+	}()
+
 	req, err := http.NewRequestWithContext(ctx, "GET", feedURL, nil)
 	if err != nil {
 		return nil, err
 	}
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	if err := checkScheme(req.URL.Scheme); err != nil {
+		return nil, err
+	}
+	resp, err := f.do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
+	info.Status = resp.StatusCode
+	wire.ReadCloser = resp.Body
+	resp.Body = wire
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected HTTP status: %s (%d)", resp.Status, resp.StatusCode)
+		return nil, &HTTPError{StatusCode: resp.StatusCode, Status: resp.Status}
 	}
 
-	data, err := io.ReadAll(resp.Body)
+	body, err := decodeBody(resp, f.maxBodySize, f.maxDecodedSize)
 	if err != nil {
 		return nil, err
 	}
-	var feed RSSFeed
-	if err := xml.Unmarshal(data, &feed); err != nil {
+	channel, err = Stream(body, func(item RSSItem) error {
+		info.Items++
+		return fn(item)
+	})
+	if err != nil {
 		return nil, err
 	}
-	feed.Channel.Title = html.UnescapeString(feed.Channel.Title)
-	feed.Channel.Description = html.UnescapeString(feed.Channel.Description)
-	for i := range feed.Channel.Item {
-		feed.Channel.Item[i].Title = html.UnescapeString(feed.Channel.Item[i].Title)
-		feed.Channel.Item[i].Description = html.UnescapeString(feed.Channel.Item[i].Description)
+	// WebSub lets publishers advertise the hub in headers instead
+	links := parseLinkHeader(resp.Header.Values("Link"))
+	if channel.Hub == "" {
+		channel.Hub = links["hub"]
+	}
+	if channel.Self == "" {
+		channel.Self = links["self"]
+	}
+	return channel, nil
+}
+
+// parseLinkHeader returns the first URL for each rel in Link headers like
+// `<https://hub.example.com/>; rel="hub"`.
+func parseLinkHeader(values []string) map[string]string {
+	links := make(map[string]string)
+	for _, value := range values {
+		for _, link := range strings.Split(value, ",") {
+			parts := strings.Split(link, ";")
+			target := strings.TrimSpace(parts[0])
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			target = target[1 : len(target)-1]
+			for _, param := range parts[1:] {
+				name, val, ok := strings.Cut(strings.TrimSpace(param), "=")
+				if !ok || !strings.EqualFold(strings.TrimSpace(name), "rel") {
+					continue
+				}
+				for _, rel := range strings.Fields(strings.Trim(strings.TrimSpace(val), `"`)) {
+					rel = strings.ToLower(rel)
+					if _, seen := links[rel]; !seen {
+						links[rel] = target
+					}
+				}
+			}
+		}
 	}
-	return &feed, nil
+	return links
 }
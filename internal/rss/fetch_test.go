@@ -0,0 +1,78 @@
+package rss
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// slowServer stalls every request until the client goes away. With
+// headersFirst it sends the status line and the start of a feed first, so
+// the stall happens mid-body.
+func slowServer(t *testing.T, headersFirst bool) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if headersFirst {
+			w.Header().Set("Content-Type", "application/rss+xml")
+			w.Write([]byte(`<rss><channel><title>Slow</title>`))
+			w.(http.Flusher).Flush()
+		}
+		<-r.Context().Done()
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestFetchStreamTimeout(t *testing.T) {
+	for _, headersFirst := range []bool{false, true} {
+		srv := slowServer(t, headersFirst)
+		f, err := NewFetcher(FetcherConfig{Timeout: 100 * time.Millisecond})
+		if err != nil {
+			t.Fatal(err)
+		}
+		start := time.Now()
+		_, err = f.FetchStream(context.Background(), srv.URL, func(RSSItem) error { return nil })
+		if err == nil {
+			t.Fatalf("headersFirst=%v: fetch from a stalled server succeeded", headersFirst)
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("headersFirst=%v: fetch took %v, want about the 100ms timeout", headersFirst, elapsed)
+		}
+	}
+}
+
+func TestFetchStreamContextDeadline(t *testing.T) {
+	srv := slowServer(t, true)
+	// The fetcher's own timeout is long; the caller's deadline must win
+	f, err := NewFetcher(FetcherConfig{Timeout: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = f.FetchStream(ctx, srv.URL, func(RSSItem) error { return nil })
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestFetchStreamCancel(t *testing.T) {
+	srv := slowServer(t, false)
+	f, err := NewFetcher(FetcherConfig{Timeout: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	_, err = f.FetchStream(ctx, srv.URL, func(RSSItem) error { return nil })
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("cancelled fetch took %v to return", elapsed)
+	}
+}
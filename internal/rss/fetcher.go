@@ -0,0 +1,228 @@
+package rss
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultUserAgent    = "Gator/1.0 (+https://github.com/JadedPigeon/Gator)"
+	defaultMaxRedirects = 10
+)
+
+// FetcherConfig configures the HTTP client used to fetch feeds. Zero values
+// fall back to sensible defaults.
+type FetcherConfig struct {
+	UserAgent string
+	Timeout   time.Duration
+	// MaxRedirects defaults to 10 when zero. A negative value turns
+	// redirects off.
+	MaxRedirects int
+	// ProxyURL overrides the HTTP_PROXY/HTTPS_PROXY environment variables.
+	ProxyURL string
+	// CABundle is a PEM file of extra root certificates to trust.
+	CABundle string
+	// HostHeaders adds request headers per host name, e.g. an API key one
+	// publisher asks for. They are set again on every redirect and removed
+	// when a redirect leaves the host.
+	HostHeaders map[string]map[string]string
+	// MaxBodySize caps the bytes read off the wire and MaxDecodedSize the
+	// bytes after decompression.
+	MaxBodySize    int64
+	MaxDecodedSize int64
+	// BlockPrivateNetworks refuses to connect to loopback, private and
+	// link-local addresses.
+	BlockPrivateNetworks bool
+	// Logger gets a debug message for each feed fetch; nil discards them.
+	Logger *slog.Logger
+	// OnFetch, if set, is called after each feed fetch, e.g. for metrics.
+	OnFetch func(FetchInfo)
+}
+
+// Fetcher fetches feeds over a single shared client so connections are
+// reused across fetches.
+type Fetcher struct {
+	client *http.Client
+	// downloadClient shares client's transport but has no overall timeout,
+	// since media files can take far longer than a feed
+	downloadClient *http.Client
+	userAgent      string
+	hostHeaders    map[string]map[string]string
+	maxBodySize    int64
+	maxDecodedSize int64
+	log            *slog.Logger
+	onFetch        func(FetchInfo)
+}
+
+func NewFetcher(cfg FetcherConfig) (*Fetcher, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = 4
+	if cfg.BlockPrivateNetworks {
+		dialer := &net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+			Control:   blockPrivateNetworks,
+		}
+		transport.DialContext = dialer.DialContext
+	}
+
+	if cfg.ProxyURL != "" {
+		proxy, err := url.Parse(cfg.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL %q: %v", cfg.ProxyURL, err)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	if cfg.CABundle != "" {
+		pem, err := os.ReadFile(cfg.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("error reading CA bundle: %v", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", cfg.CABundle)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	maxRedirects := cfg.MaxRedirects
+	if maxRedirects == 0 {
+		maxRedirects = defaultMaxRedirects
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	userAgent := cfg.UserAgent
+	if userAgent == "" {
+		userAgent = DefaultUserAgent
+	}
+
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+
+	hostHeaders := cfg.HostHeaders
+	checkRedirect := func(req *http.Request, via []*http.Request) error {
+		if maxRedirects < 0 {
+			return fmt.Errorf("redirected to %s, but redirects are turned off", req.URL)
+		}
+		// via holds the original request too, so it is one longer than the
+		// number of redirects followed so far
+		if len(via) > maxRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxRedirects)
+		}
+		if err := checkScheme(req.URL.Scheme); err != nil {
+			return err
+		}
+		// net/http copies the first request's headers onto every hop, so
+		// another host would otherwise see the original host's secrets
+		setHostHeaders(req, via, hostHeaders)
+		return nil
+	}
+	return &Fetcher{
+		client: &http.Client{
+			Transport:     transport,
+			Timeout:       timeout,
+			CheckRedirect: checkRedirect,
+		},
+		downloadClient: &http.Client{
+			Transport:     transport,
+			CheckRedirect: checkRedirect,
+		},
+		userAgent:      userAgent,
+		hostHeaders:    hostHeaders,
+		maxBodySize:    orDefault(cfg.MaxBodySize, defaultMaxBodySize),
+		maxDecodedSize: orDefault(cfg.MaxDecodedSize, defaultMaxDecodedSize),
+		log:            logger,
+		onFetch:        cfg.OnFetch,
+	}, nil
+}
+
+func orDefault(n, def int64) int64 {
+	if n <= 0 {
+		return def
+	}
+	return n
+}
+
+var (
+	defaultFetcher     *Fetcher
+	defaultFetcherOnce sync.Once
+)
+
+// DefaultFetcher returns a shared Fetcher with the default configuration.
+func DefaultFetcher() *Fetcher {
+	defaultFetcherOnce.Do(func() {
+		f, err := NewFetcher(FetcherConfig{})
+		if err != nil {
+			panic(err)
+		}
+		defaultFetcher = f
+	})
+	return defaultFetcher
+}
+
+// setHostHeaders applies the headers configured for req's host, after
+// removing any that were configured for an earlier hop on a different host.
+func setHostHeaders(req *http.Request, via []*http.Request, hostHeaders map[string]map[string]string) {
+	host := req.URL.Hostname()
+	for _, prev := range via {
+		if prevHost := prev.URL.Hostname(); prevHost != host {
+			for name := range hostHeaders[prevHost] {
+				req.Header.Del(name)
+			}
+		}
+	}
+	for name, value := range hostHeaders[host] {
+		req.Header.Set(name, value)
+	}
+}
+
+// do sends req with the user agent and any per-host headers applied.
+func (f *Fetcher) do(req *http.Request) (*http.Response, error) {
+	req.Header.Set("User-Agent", f.userAgent)
+	// Asking explicitly means the transport leaves decoding to us, so the
+	// decompressed size can be capped
+	req.Header.Set("Accept-Encoding", "gzip, deflate, br")
+	setHostHeaders(req, nil, f.hostHeaders)
+	return f.client.Do(req)
+}
+
+// Download sends a request for a media file. Unlike feed fetches it has no
+// overall timeout and no size limit, so callers should bound it with the
+// request's context. The body is returned as sent, without decompression,
+// so byte ranges line up with the file.
+func (f *Fetcher) Download(req *http.Request) (*http.Response, error) {
+	if err := checkScheme(req.URL.Scheme); err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", f.userAgent)
+	req.Header.Set("Accept-Encoding", "identity")
+	setHostHeaders(req, nil, f.hostHeaders)
+	return f.downloadClient.Do(req)
+}
+
+// Send makes a request that isn't a feed fetch, such as a WebSub
+// subscription, with the same headers, timeout and network restrictions.
+func (f *Fetcher) Send(req *http.Request) (*http.Response, error) {
+	if err := checkScheme(req.URL.Scheme); err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", f.userAgent)
+	setHostHeaders(req, nil, f.hostHeaders)
+	return f.client.Do(req)
+}
@@ -0,0 +1,229 @@
+package rss
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+const testFeed = `<?xml version="1.0"?>
+<rss version="2.0"><channel><title>Test</title>
+<item><title>One</title><link>https://example.com/1</link></item>
+<item><title>Two</title><link>https://example.com/2</link></item>
+</channel></rss>`
+
+// feedServer serves testFeed and hands each request to inspect first.
+func feedServer(t *testing.T, inspect func(r *http.Request)) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if inspect != nil {
+			inspect(r)
+		}
+		w.Write([]byte(testFeed))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func mustFetcher(t *testing.T, cfg FetcherConfig) *Fetcher {
+	t.Helper()
+	f, err := NewFetcher(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestFetcherUserAgent(t *testing.T) {
+	var got string
+	srv := feedServer(t, func(r *http.Request) { got = r.UserAgent() })
+
+	if _, err := mustFetcher(t, FetcherConfig{}).Fetch(context.Background(), srv.URL); err != nil {
+		t.Fatal(err)
+	}
+	if got != DefaultUserAgent {
+		t.Errorf("default User-Agent = %q, want %q", got, DefaultUserAgent)
+	}
+
+	custom := "Gator-Test/2.0 (+mailto:ops@example.com)"
+	if _, err := mustFetcher(t, FetcherConfig{UserAgent: custom}).Fetch(context.Background(), srv.URL); err != nil {
+		t.Fatal(err)
+	}
+	if got != custom {
+		t.Errorf("User-Agent = %q, want %q", got, custom)
+	}
+}
+
+func TestFetcherHostHeaders(t *testing.T) {
+	var got http.Header
+	srv := feedServer(t, func(r *http.Request) { got = r.Header.Clone() })
+	f := mustFetcher(t, FetcherConfig{HostHeaders: map[string]map[string]string{
+		"127.0.0.1":         {"X-Api-Key": "secret"},
+		"other.example.com": {"X-Other": "nope"},
+	}})
+	if _, err := f.Fetch(context.Background(), srv.URL); err != nil {
+		t.Fatal(err)
+	}
+	if v := got.Get("X-Api-Key"); v != "secret" {
+		t.Errorf("X-Api-Key = %q, want %q", v, "secret")
+	}
+	if v := got.Get("X-Other"); v != "" {
+		t.Errorf("header for another host was sent: X-Other = %q", v)
+	}
+}
+
+func TestFetcherHostHeadersAcrossRedirect(t *testing.T) {
+	// The feed lives on 127.0.0.1 but is first requested as localhost, so the
+	// redirect changes host name
+	var got http.Header
+	feed := feedServer(t, func(r *http.Request) { got = r.Header.Clone() })
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != "secret" {
+			t.Errorf("first hop X-Api-Key = %q, want %q", r.Header.Get("X-Api-Key"), "secret")
+		}
+		http.Redirect(w, r, feed.URL, http.StatusFound)
+	}))
+	defer origin.Close()
+	f := mustFetcher(t, FetcherConfig{HostHeaders: map[string]map[string]string{
+		"localhost": {"X-Api-Key": "secret"},
+		"127.0.0.1": {"X-Other": "target"},
+	}})
+
+	originURL := strings.Replace(origin.URL, "127.0.0.1", "localhost", 1)
+	if _, err := f.Fetch(context.Background(), originURL); err != nil {
+		t.Fatal(err)
+	}
+	if v := got.Get("X-Api-Key"); v != "" {
+		t.Errorf("header for the first host followed the redirect: X-Api-Key = %q", v)
+	}
+	if v := got.Get("X-Other"); v != "target" {
+		t.Errorf("X-Other = %q, want the redirect target's header %q", v, "target")
+	}
+}
+
+func TestFetcherRedirectsOff(t *testing.T) {
+	feed := feedServer(t, nil)
+	srv := httptest.NewServer(http.RedirectHandler(feed.URL, http.StatusFound))
+	defer srv.Close()
+
+	_, err := mustFetcher(t, FetcherConfig{MaxRedirects: -1}).Fetch(context.Background(), srv.URL)
+	if err == nil || !strings.Contains(err.Error(), "redirects are turned off") {
+		t.Errorf("got %v, want an error about redirects being off", err)
+	}
+}
+
+func TestFetcherRedirectLimit(t *testing.T) {
+	// /hop/n redirects to /hop/n-1 until /hop/0, which serves the feed
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/hop/"))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		if n > 0 {
+			http.Redirect(w, r, fmt.Sprintf("/hop/%d", n-1), http.StatusFound)
+			return
+		}
+		w.Write([]byte(testFeed))
+	}))
+	defer srv.Close()
+	f := mustFetcher(t, FetcherConfig{MaxRedirects: 3})
+
+	feed, err := f.Fetch(context.Background(), srv.URL+"/hop/3")
+	if err != nil {
+		t.Fatalf("3 redirects with a limit of 3: %v", err)
+	}
+	if len(feed.Channel.Item) != 2 {
+		t.Errorf("got %d items, want 2", len(feed.Channel.Item))
+	}
+
+	_, err = f.Fetch(context.Background(), srv.URL+"/hop/4")
+	if err == nil || !strings.Contains(err.Error(), "stopped after 3 redirects") {
+		t.Errorf("4 redirects with a limit of 3: got %v, want a redirect limit error", err)
+	}
+}
+
+func TestFetcherRedirectToOtherScheme(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "file:///etc/passwd", http.StatusFound)
+	}))
+	defer srv.Close()
+	_, err := mustFetcher(t, FetcherConfig{}).Fetch(context.Background(), srv.URL)
+	if err == nil || !strings.Contains(err.Error(), "unsupported URL scheme") {
+		t.Errorf("got %v, want an unsupported scheme error", err)
+	}
+}
+
+func TestFetcherProxy(t *testing.T) {
+	// A plain HTTP proxy sees the absolute URL of the feed it is asked for
+	var requested string
+	proxy := feedServer(t, func(r *http.Request) { requested = r.URL.String() })
+	f := mustFetcher(t, FetcherConfig{ProxyURL: proxy.URL})
+
+	feedURL := "http://feeds.example.invalid/blog.xml"
+	feed, err := f.Fetch(context.Background(), feedURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requested != feedURL {
+		t.Errorf("proxy got a request for %q, want %q", requested, feedURL)
+	}
+	if feed.Channel.Title != "Test" {
+		t.Errorf("title = %q, want the feed served by the proxy", feed.Channel.Title)
+	}
+}
+
+func TestFetcherInvalidProxy(t *testing.T) {
+	if _, err := NewFetcher(FetcherConfig{ProxyURL: "://bad"}); err == nil {
+		t.Error("NewFetcher accepted an invalid proxy URL")
+	}
+}
+
+func TestFetcherReusesConnections(t *testing.T) {
+	var conns atomic.Int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testFeed))
+	}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	f := mustFetcher(t, FetcherConfig{})
+	for i := 0; i < 5; i++ {
+		if _, err := f.Fetch(context.Background(), srv.URL); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := conns.Load(); n != 1 {
+		t.Errorf("5 sequential fetches opened %d connections, want 1", n)
+	}
+}
+
+func TestDefaultFetcherIsShared(t *testing.T) {
+	var wg sync.WaitGroup
+	fetchers := make([]*Fetcher, 4)
+	for i := range fetchers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fetchers[i] = DefaultFetcher()
+		}()
+	}
+	wg.Wait()
+	for _, f := range fetchers[1:] {
+		if f != fetchers[0] {
+			t.Fatal("DefaultFetcher returned different fetchers")
+		}
+	}
+}
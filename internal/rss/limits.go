@@ -0,0 +1,118 @@
+package rss
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+
+	"github.com/andybalholm/brotli"
+)
+
+const (
+	// Items are streamed, so these bound download time rather than memory
+	defaultMaxBodySize    = 32 << 20  // 32 MiB on the wire
+	defaultMaxDecodedSize = 128 << 20 // 128 MiB after decompression
+)
+
+// ErrTooLarge is returned when a feed exceeds the configured size limits.
+var ErrTooLarge = errors.New("feed is too large")
+
+// limitedReader fails with ErrTooLarge instead of silently truncating, so a
+// cut-off feed is never mistaken for a complete one.
+type limitedReader struct {
+	r    io.Reader
+	left int64
+	what string
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.left <= 0 {
+		// Probe for one more byte to tell "exactly at the limit" from "over"
+		var one [1]byte
+		n, err := l.r.Read(one[:])
+		if n > 0 {
+			return 0, fmt.Errorf("%w: %s exceeds the limit", ErrTooLarge, l.what)
+		}
+		return 0, err
+	}
+	if int64(len(p)) > l.left {
+		p = p[:l.left]
+	}
+	n, err := l.r.Read(p)
+	l.left -= int64(n)
+	return n, err
+}
+
+// decodeBody undoes the response's Content-Encoding.
+func decodeBody(resp *http.Response, maxBody, maxDecoded int64) (io.Reader, error) {
+	body := &limitedReader{r: resp.Body, left: maxBody, what: "response body"}
+	var decoded io.Reader
+	switch enc := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))); enc {
+	case "", "identity":
+		return body, nil
+	case "br":
+		decoded = brotli.NewReader(body)
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip body: %v", err)
+		}
+		decoded = zr
+	case "deflate":
+		// "deflate" should be zlib-wrapped, but some servers send raw deflate
+		br := bufio.NewReader(body)
+		header, _ := br.Peek(2)
+		if len(header) == 2 && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+			zr, err := zlib.NewReader(br)
+			if err != nil {
+				return nil, fmt.Errorf("invalid deflate body: %v", err)
+			}
+			decoded = zr
+		} else {
+			decoded = flate.NewReader(br)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", enc)
+	}
+	return &limitedReader{r: decoded, left: maxDecoded, what: "decompressed feed"}, nil
+}
+
+func checkScheme(scheme string) error {
+	if scheme != "http" && scheme != "https" {
+		return fmt.Errorf("unsupported URL scheme %q: only http and https feeds are allowed", scheme)
+	}
+	return nil
+}
+
+var cgnat = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// isPrivateIP reports addresses a feed on the public internet should never
+// resolve to.
+func isPrivateIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || cgnat.Contains(ip)
+}
+
+// blockPrivateNetworks is a net.Dialer Control function. It runs after DNS
+// resolution, so a public hostname that resolves to a private address is
+// caught too, including on redirects.
+func blockPrivateNetworks(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || isPrivateIP(ip) {
+		return fmt.Errorf("refusing to connect to private address %s", host)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package rss
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+// encodedServer serves body with the given Content-Encoding.
+func encodedServer(t *testing.T, encoding string, body []byte) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if encoding != "" {
+			w.Header().Set("Content-Encoding", encoding)
+		}
+		w.Write(body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func compress(t *testing.T, encoding string, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "raw-deflate":
+		w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	default:
+		return data
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestFetchContentEncodings(t *testing.T) {
+	for _, tt := range []struct{ encoding, header string }{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"deflate", "deflate"},
+		{"raw-deflate", "deflate"},
+		{"br", "br"},
+	} {
+		var accept string
+		body := compress(t, tt.encoding, []byte(testFeed))
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			accept = r.Header.Get("Accept-Encoding")
+			if tt.header != "" {
+				w.Header().Set("Content-Encoding", tt.header)
+			}
+			w.Write(body)
+		}))
+		defer srv.Close()
+		feed, err := mustFetcher(t, FetcherConfig{}).Fetch(context.Background(), srv.URL)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.encoding, err)
+		}
+		if len(feed.Channel.Item) != 2 {
+			t.Errorf("%q: got %d items, want 2", tt.encoding, len(feed.Channel.Item))
+		}
+		if accept != "gzip, deflate, br" {
+			t.Errorf("Accept-Encoding = %q", accept)
+		}
+	}
+}
+
+func TestFetchDecodedSizeLimit(t *testing.T) {
+	// Highly compressible padding stays under the body limit but not the
+	// decoded one
+	doc := []byte(`<rss><channel><title>` + strings.Repeat("x", 1<<20) + `</title></channel></rss>`)
+	for _, encoding := range []string{"gzip", "br"} {
+		srv := encodedServer(t, encoding, compress(t, encoding, doc))
+		f := mustFetcher(t, FetcherConfig{MaxBodySize: 64 << 10, MaxDecodedSize: 512 << 10})
+		_, err := f.Fetch(context.Background(), srv.URL)
+		if !errors.Is(err, ErrTooLarge) || !strings.Contains(err.Error(), "decompressed feed") {
+			t.Errorf("%s: got %v, want a decompressed size error", encoding, err)
+		}
+	}
+}
+
+func TestFetchBodySizeLimit(t *testing.T) {
+	srv := encodedServer(t, "", []byte(testFeed))
+	f := mustFetcher(t, FetcherConfig{MaxBodySize: 32})
+	if _, err := f.Fetch(context.Background(), srv.URL); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("got %v, want ErrTooLarge", err)
+	}
+}
+
+func TestFetchUnsupportedEncoding(t *testing.T) {
+	srv := encodedServer(t, "zstd", []byte(testFeed))
+	_, err := mustFetcher(t, FetcherConfig{}).Fetch(context.Background(), srv.URL)
+	if err == nil || !strings.Contains(err.Error(), "unsupported content encoding") {
+		t.Errorf("got %v, want an unsupported encoding error", err)
+	}
+}
@@ -0,0 +1,88 @@
+package rss
+
+import (
+	"strconv"
+	"strings"
+)
+
+type MediaThumbnail struct {
+	URL    string `xml:"url,attr"`
+	Width  string `xml:"width,attr"`
+	Height string `xml:"height,attr"`
+}
+
+type MediaContent struct {
+	URL        string           `xml:"url,attr"`
+	Type       string           `xml:"type,attr"`
+	Medium     string           `xml:"medium,attr"`
+	Width      string           `xml:"width,attr"`
+	Height     string           `xml:"height,attr"`
+	Thumbnails []MediaThumbnail `xml:"http://search.yahoo.com/mrss/ thumbnail"`
+}
+
+// MediaGroup bundles alternative versions of the same media.
+type MediaGroup struct {
+	Contents   []MediaContent   `xml:"http://search.yahoo.com/mrss/ content"`
+	Thumbnails []MediaThumbnail `xml:"http://search.yahoo.com/mrss/ thumbnail"`
+}
+
+// Thumbnail is a preview image with its size in pixels, 0 if unknown.
+type Thumbnail struct {
+	URL    string
+	Width  int32
+	Height int32
+}
+
+// Thumbnails collects the item's preview images: media:thumbnail wherever
+// it appears, then media:content that is itself an image. Duplicate URLs
+// are dropped.
+func (item RSSItem) Thumbnails() []Thumbnail {
+	var thumbs []Thumbnail
+	seen := make(map[string]bool)
+	addThumb := func(url, width, height string) {
+		url = strings.TrimSpace(url)
+		if url == "" || seen[url] {
+			return
+		}
+		seen[url] = true
+		thumbs = append(thumbs, Thumbnail{URL: url, Width: positiveInt(width), Height: positiveInt(height)})
+	}
+
+	contents := item.MediaContents
+	for _, t := range item.MediaThumbnails {
+		addThumb(t.URL, t.Width, t.Height)
+	}
+	for _, g := range item.MediaGroups {
+		for _, t := range g.Thumbnails {
+			addThumb(t.URL, t.Width, t.Height)
+		}
+		contents = append(contents, g.Contents...)
+	}
+	for _, c := range contents {
+		for _, t := range c.Thumbnails {
+			addThumb(t.URL, t.Width, t.Height)
+		}
+	}
+	for _, c := range contents {
+		if c.isImage() {
+			addThumb(c.URL, c.Width, c.Height)
+		}
+	}
+	return thumbs
+}
+
+func (c MediaContent) isImage() bool {
+	if c.Medium != "" {
+		return c.Medium == "image"
+	}
+	return strings.HasPrefix(c.Type, "image/")
+}
+
+// positiveInt returns 0 when the number is missing or invalid.
+func positiveInt(s string) int32 {
+	n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 32)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return int32(n)
+}
@@ -0,0 +1,164 @@
+package rss
+
+import (
+	"encoding/xml"
+	"html"
+	"io"
+	"strings"
+)
+
+// rss1Namespace is the default namespace of RSS 1.0 (RDF) feeds.
+const rss1Namespace = "http://purl.org/rss/1.0/"
+
+// Parse decodes a whole RSS document. Large feeds should use Stream instead,
+// which never holds more than one item in memory.
+func Parse(r io.Reader) (*RSSFeed, error) {
+	var items []RSSItem
+	channel, err := Stream(r, func(item RSSItem) error {
+		items = append(items, item)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	channel.Item = items
+	return &RSSFeed{Channel: *channel}, nil
+}
+
+// Stream decodes an RSS document token by token and calls fn for each item
+// as soon as it has been read. If fn returns an error, parsing stops and
+// that error is returned. The returned channel has no items.
+//
+// encoding/xml never expands external or user-defined entities, so
+// untrusted input can't pull in files or blow up through nested entity
+// definitions.
+func Stream(r io.Reader, fn func(RSSItem) error) (*RSSChannel, error) {
+	dec := xml.NewDecoder(r)
+	dec.CharsetReader = charsetReader
+
+	var channel RSSChannel
+	var parents []string
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return &channel, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			// RSS 2.0 nests items in <channel>, RSS 1.0 puts them next to it
+			if t.Name.Local == "item" && isRSSNamespace(t.Name.Space) {
+				var item RSSItem
+				if err := dec.DecodeElement(&item, &t); err != nil {
+					return nil, err
+				}
+				if item.Title == "" {
+					item.Title = item.ITunesTitle
+				}
+				if item.Title == "" {
+					item.Title = item.MediaTitle
+				}
+				if item.Description == "" {
+					item.Description = item.MediaDescription
+				}
+				if item.Link == "" {
+					item.Link = item.alternateLink()
+				}
+				item.Title = html.UnescapeString(item.Title)
+				item.Description = html.UnescapeString(item.Description)
+				if item.Author == "" {
+					item.Author = item.Creator
+				}
+				if err := fn(item); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if len(parents) > 0 && parents[len(parents)-1] == "channel" && t.Name.Space == atomNamespace && t.Name.Local == "link" {
+				rel, href := attr(t, "rel"), attr(t, "href")
+				if rel == "hub" && channel.Hub == "" {
+					channel.Hub = href
+				} else if rel == "self" && channel.Self == "" {
+					channel.Self = href
+				}
+				if err := dec.Skip(); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if len(parents) > 0 && parents[len(parents)-1] == "channel" && isRSSNamespace(t.Name.Space) {
+				var field *string
+				switch t.Name.Local {
+				case "title":
+					field = &channel.Title
+				case "link":
+					field = &channel.Link
+				case "description":
+					field = &channel.Description
+				}
+				if field != nil {
+					if err := dec.DecodeElement(field, &t); err != nil {
+						return nil, err
+					}
+					*field = html.UnescapeString(*field)
+					continue
+				}
+			}
+			parents = append(parents, t.Name.Local)
+		case xml.EndElement:
+			if len(parents) > 0 {
+				parents = parents[:len(parents)-1]
+			}
+		}
+	}
+}
+
+// alternateLink returns the href of the item's first atom:link that points
+// at the post itself, i.e. has no rel or rel="alternate".
+func (item RSSItem) alternateLink() string {
+	for _, l := range item.AtomLinks {
+		if l.Rel == "" || l.Rel == "alternate" {
+			return strings.TrimSpace(l.Href)
+		}
+	}
+	return ""
+}
+
+func attr(el xml.StartElement, name string) string {
+	for _, a := range el.Attr {
+		if a.Name.Space == "" && a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+func isRSSNamespace(space string) bool {
+	return space == "" || space == rss1Namespace
+}
+
+// Excerpt turns an HTML description into plain text of at most n runes,
+// for previews.
+func Excerpt(s string, n int) string {
+	var b strings.Builder
+	inTag := false
+	for _, r := range s {
+		switch {
+		case r == '<':
+			inTag = true
+		case r == '>' && inTag:
+			inTag = false
+			b.WriteRune(' ')
+		case !inTag:
+			b.WriteRune(r)
+		}
+	}
+	text := strings.Join(strings.Fields(html.UnescapeString(b.String())), " ")
+	if runes := []rune(text); len(runes) > n {
+		text = string(runes[:n]) + "…"
+	}
+	return text
+}
@@ -0,0 +1,300 @@
+package rss
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// fuzzSeeds covers the shapes Stream handles specially: RSS 2.0, RSS 1.0,
+// Atom links in the channel, iTunes titles and a declared charset.
+var fuzzSeeds = []string{
+	testFeed,
+	`<?xml version="1.0"?>
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns="http://purl.org/rss/1.0/">
+<channel><title>RDF</title></channel>
+<item><title>One</title><link>https://example.com/1</link></item>
+</rdf:RDF>`,
+	`<rss xmlns:atom="http://www.w3.org/2005/Atom" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd"><channel>
+<atom:link rel="hub" href="https://hub.example.com/"/><atom:link rel="self" href="https://example.com/feed"/>
+<item><itunes:title>Episode &amp;amp; more</itunes:title><enclosure url="https://example.com/1.mp3" length="12" type="audio/mpeg"/></item>
+</channel></rss>`,
+	"<?xml version=\"1.0\" encoding=\"windows-1252\"?><rss><channel><title>\x93Hi\x94</title></channel></rss>",
+	`<rss><channel><title>Unclosed`,
+	``,
+}
+
+func FuzzStream(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		n := 0
+		channel, err := Stream(bytes.NewReader(data), func(RSSItem) error {
+			n++
+			return nil
+		})
+		if err != nil {
+			return
+		}
+		if len(channel.Item) != 0 {
+			t.Errorf("Stream returned a channel with %d items", len(channel.Item))
+		}
+
+		// Stopping early must hand back fn's error unchanged
+		stop := errors.New("stop")
+		_, err = Stream(bytes.NewReader(data), func(RSSItem) error { return stop })
+		if n > 0 && err != stop {
+			t.Errorf("fn's error was not returned: got %v", err)
+		}
+	})
+}
+
+func FuzzParse(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		feed, err := Parse(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		// Parse is Stream plus collecting the items
+		var items []RSSItem
+		channel, err := Stream(bytes.NewReader(data), func(item RSSItem) error {
+			items = append(items, item)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Parse succeeded but Stream failed: %v", err)
+		}
+		if len(feed.Channel.Item) != len(items) {
+			t.Errorf("Parse found %d items, Stream %d", len(feed.Channel.Item), len(items))
+		}
+		if feed.Channel.Title != channel.Title {
+			t.Errorf("Parse title %q, Stream title %q", feed.Channel.Title, channel.Title)
+		}
+	})
+}
+
+func TestCharsetReaderWindows1252(t *testing.T) {
+	// Every byte from 0x80 to 0xFF decodes to one rune, and ASCII passes through
+	in := []byte("a\x80\x93q\x94\x99\x81\xe9\xff")
+	want := "a€“q”™\u0081éÿ"
+	for _, label := range []string{"windows-1252", "CP1252", " iso-8859-1 ", "latin1"} {
+		r, err := charsetReader(label, bytes.NewReader(in))
+		if err != nil {
+			t.Fatalf("%s: %v", label, err)
+		}
+		got, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("%s: %v", label, err)
+		}
+		if string(got) != want {
+			t.Errorf("%s: got %q, want %q", label, got, want)
+		}
+	}
+}
+
+func TestCharsetReaderSmallReads(t *testing.T) {
+	// A multi-byte rune must survive reads too small to hold it
+	r, err := charsetReader("windows-1252", strings.NewReader("\x80\x80"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []byte
+	buf := make([]byte, 1)
+	for {
+		n, err := r.Read(buf)
+		got = append(got, buf[:n]...)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if string(got) != "€€" {
+		t.Errorf("got %q, want %q", got, "€€")
+	}
+}
+
+func TestCharsetReaderUnsupported(t *testing.T) {
+	if _, err := charsetReader("shift_jis", strings.NewReader("")); err == nil {
+		t.Error("charsetReader accepted shift_jis")
+	}
+}
+
+func TestParseWindows1252Feed(t *testing.T) {
+	doc := "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?>\n" +
+		"<rss><channel><title>Caf\xe9</title><item><title>\x93Quoted\x94 \x96 5\x80</title></item></channel></rss>"
+	feed, err := Parse(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if feed.Channel.Title != "Café" {
+		t.Errorf("channel title = %q, want %q", feed.Channel.Title, "Café")
+	}
+	if got, want := feed.Channel.Item[0].Title, "“Quoted” – 5€"; got != want {
+		t.Errorf("item title = %q, want %q", got, want)
+	}
+}
+
+func TestParseNamespacedItemFields(t *testing.T) {
+	const ns = `xmlns:media="http://search.yahoo.com/mrss/" xmlns:atom="http://www.w3.org/2005/Atom"`
+	tests := []struct {
+		name                     string
+		item                     string
+		title, link, description string
+	}{
+		{
+			name:        "media title and description after the RSS ones",
+			item:        `<title>Post</title><link>https://example.com/post</link><description>Body</description><media:title>Video</media:title><media:description>Caption</media:description>`,
+			title:       "Post",
+			link:        "https://example.com/post",
+			description: "Body",
+		},
+		{
+			name:        "media title and description before the RSS ones",
+			item:        `<media:title>Video</media:title><media:description>Caption</media:description><title>Post</title><link>https://example.com/post</link><description>Body</description>`,
+			title:       "Post",
+			link:        "https://example.com/post",
+			description: "Body",
+		},
+		{
+			name:        "media title and description only",
+			item:        `<link>https://example.com/post</link><media:title>Video</media:title><media:description>Caption</media:description>`,
+			title:       "Video",
+			link:        "https://example.com/post",
+			description: "Caption",
+		},
+		{
+			name:  "atom:link after link",
+			item:  `<title>Post</title><link>https://example.com/post</link><atom:link rel="self" href="https://example.com/post.xml"/>`,
+			title: "Post",
+			link:  "https://example.com/post",
+		},
+		{
+			name:  "atom:link only",
+			item:  `<title>Post</title><atom:link rel="replies" href="https://example.com/post#comments"/><atom:link href="https://example.com/post"/>`,
+			title: "Post",
+			link:  "https://example.com/post",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := `<rss version="2.0" ` + ns + `><channel><title>Feed</title><item>` + tt.item + `</item></channel></rss>`
+			feed, err := Parse(strings.NewReader(doc))
+			if err != nil {
+				t.Fatal(err)
+			}
+			item := feed.Channel.Item[0]
+			if item.Title != tt.title {
+				t.Errorf("Title = %q, want %q", item.Title, tt.title)
+			}
+			if item.Link != tt.link {
+				t.Errorf("Link = %q, want %q", item.Link, tt.link)
+			}
+			if item.Description != tt.description {
+				t.Errorf("Description = %q, want %q", item.Description, tt.description)
+			}
+		})
+	}
+}
+
+// largeFeed builds an RSS document with n items of realistic size.
+func largeFeed(n int) []byte {
+	var b bytes.Buffer
+	b.WriteString(`<?xml version="1.0"?><rss version="2.0"><channel><title>Archive</title><link>https://example.com/</link>`)
+	body := strings.Repeat("Lorem ipsum dolor sit amet, consectetur adipiscing elit. ", 20)
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, `<item><title>Post %d</title><link>https://example.com/posts/%d</link>`+
+			`<guid>https://example.com/posts/%d</guid><pubDate>Mon, 02 Jan 2006 15:04:05 GMT</pubDate>`+
+			`<description>%s</description></item>`, i, i, i, body)
+	}
+	b.WriteString(`</channel></rss>`)
+	return b.Bytes()
+}
+
+// unmarshalFeed is how feeds were parsed before Stream: the whole body is
+// read into memory and then decoded in one go. It stays here as the
+// baseline for the benchmarks below. sample is called while the body and
+// the decoded feed are both alive, which is when its heap peaks.
+func unmarshalFeed(r io.Reader, sample func()) (*RSSFeed, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var feed RSSFeed
+	if err := xml.Unmarshal(data, &feed); err != nil {
+		return nil, err
+	}
+	sample()
+	runtime.KeepAlive(data)
+	return &feed, nil
+}
+
+// benchmarkParser times parse over a 10,000-item feed of about 12 MB and
+// reports its peak live heap. The peak comes from one untimed pass in which
+// sample forces a collection, so only memory still in use is counted.
+func benchmarkParser(b *testing.B, parse func(r io.Reader, sample func()) error) {
+	doc := largeFeed(10000)
+
+	heapAlloc := func() uint64 {
+		runtime.GC()
+		var m runtime.MemStats
+		runtime.ReadMemStats(&m)
+		return m.HeapAlloc
+	}
+	base := heapAlloc()
+	var peak uint64
+	if err := parse(bytes.NewReader(doc), func() { peak = max(peak, heapAlloc()) }); err != nil {
+		b.Fatal(err)
+	}
+
+	b.SetBytes(int64(len(doc)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := parse(bytes.NewReader(doc), func() {}); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(peak-min(base, peak)), "peak-heap-B")
+}
+
+// Stream should allocate about as much as the baseline in total but hold
+// only one item at a time, so its peak heap stays far below the feed's size.
+func BenchmarkStream(b *testing.B) {
+	benchmarkParser(b, func(r io.Reader, sample func()) error {
+		n := 0
+		_, err := Stream(r, func(RSSItem) error {
+			if n++; n%1000 == 0 {
+				sample()
+			}
+			return nil
+		})
+		return err
+	})
+}
+
+func BenchmarkParse(b *testing.B) {
+	benchmarkParser(b, func(r io.Reader, sample func()) error {
+		feed, err := Parse(r)
+		sample()
+		runtime.KeepAlive(feed)
+		return err
+	})
+}
+
+func BenchmarkUnmarshalBaseline(b *testing.B) {
+	benchmarkParser(b, func(r io.Reader, sample func()) error {
+		_, err := unmarshalFeed(r, sample)
+		return err
+	})
+}
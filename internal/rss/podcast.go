@@ -0,0 +1,58 @@
+package rss
+
+import (
+	"strconv"
+	"strings"
+)
+
+type ITunesImage struct {
+	Href string `xml:"href,attr"`
+}
+
+// IsEpisode reports whether the item carries any podcast metadata.
+func (item RSSItem) IsEpisode() bool {
+	return item.ITunesDuration != "" || item.ITunesSeason != "" || item.ITunesEpisode != "" ||
+		item.ITunesExplicit != "" || item.ITunesImage.Href != ""
+}
+
+// DurationSeconds parses itunes:duration, which is either a number of
+// seconds or HH:MM:SS / MM:SS. It returns 0 if the value is missing or
+// invalid.
+func (item RSSItem) DurationSeconds() int32 {
+	parts := strings.Split(strings.TrimSpace(item.ITunesDuration), ":")
+	if len(parts) > 3 {
+		return 0
+	}
+	total := 0
+	for _, part := range parts {
+		// Some feeds write fractional seconds
+		part, _, _ = strings.Cut(part, ".")
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return 0
+		}
+		total = total*60 + n
+	}
+	return int32(total)
+}
+
+// Season and Episode return 0 when the number is missing or invalid.
+func (item RSSItem) Season() int32 {
+	return positiveInt(item.ITunesSeason)
+}
+
+func (item RSSItem) Episode() int32 {
+	return positiveInt(item.ITunesEpisode)
+}
+
+// Explicit returns "true", "false" or "" for unknown, ready to be cast to a
+// boolean by the database.
+func (item RSSItem) Explicit() string {
+	switch strings.ToLower(strings.TrimSpace(item.ITunesExplicit)) {
+	case "yes", "true", "explicit":
+		return "true"
+	case "no", "false", "clean":
+		return "false"
+	}
+	return ""
+}
@@ -0,0 +1,188 @@
+package rss
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+// Document is a feed to publish with WriteRSS or WriteAtom.
+type Document struct {
+	ID          string // Atom feed ID, a URI that never changes
+	Title       string
+	Link        string // HTML page for the feed
+	SelfURL     string // where the document itself is served, if anywhere
+	Description string
+	Updated     time.Time
+	Entries     []Entry
+}
+
+type Entry struct {
+	ID          string // stable URI, e.g. urn:uuid:...
+	Title       string
+	Link        string
+	Description string // HTML
+	Author      string
+	Published   time.Time // zero if unknown
+	Categories  []string
+}
+
+const atomNamespace = "http://www.w3.org/2005/Atom"
+
+type rssOut struct {
+	XMLName xml.Name `xml:"rss"`
+	Version string   `xml:"version,attr"`
+	Atom    string   `xml:"xmlns:atom,attr"`
+	DC      string   `xml:"xmlns:dc,attr"`
+	Channel struct {
+		Title         string      `xml:"title"`
+		Link          string      `xml:"link"`
+		Description   string      `xml:"description"`
+		LastBuildDate string      `xml:"lastBuildDate"`
+		Self          *atomLinkNS `xml:"atom:link"`
+		Items         []rssItemOut
+	} `xml:"channel"`
+}
+
+type atomLinkNS struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type rssItemOut struct {
+	XMLName     xml.Name `xml:"item"`
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	Description string   `xml:"description,omitempty"`
+	GUID        struct {
+		IsPermaLink string `xml:"isPermaLink,attr"`
+		Value       string `xml:",chardata"`
+	} `xml:"guid"`
+	PubDate    string   `xml:"pubDate,omitempty"`
+	Creator    string   `xml:"dc:creator,omitempty"`
+	Categories []string `xml:"category"`
+}
+
+// WriteRSS writes doc as an RSS 2.0 document.
+func WriteRSS(w io.Writer, doc Document) error {
+	var out rssOut
+	out.Version = "2.0"
+	out.Atom = atomNamespace
+	out.DC = "http://purl.org/dc/elements/1.1/"
+	out.Channel.Title = doc.Title
+	out.Channel.Link = doc.Link
+	out.Channel.Description = doc.Description
+	out.Channel.LastBuildDate = doc.Updated.UTC().Format(time.RFC1123Z)
+	if doc.SelfURL != "" {
+		out.Channel.Self = &atomLinkNS{Href: doc.SelfURL, Rel: "self", Type: "application/rss+xml"}
+	}
+	for _, e := range doc.Entries {
+		item := rssItemOut{
+			Title:       e.Title,
+			Link:        e.Link,
+			Description: e.Description,
+			Creator:     e.Author,
+			Categories:  e.Categories,
+		}
+		item.GUID.IsPermaLink = "false"
+		item.GUID.Value = e.ID
+		if !e.Published.IsZero() {
+			item.PubDate = e.Published.UTC().Format(time.RFC1123Z)
+		}
+		out.Channel.Items = append(out.Channel.Items, item)
+	}
+	return writeXML(w, out)
+}
+
+type atomOut struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Author  atomPerson  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Link       atomLink       `xml:"link"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published,omitempty"`
+	Author     *atomPerson    `xml:"author"`
+	Summary    *atomText      `xml:"summary"`
+	Categories []atomCategory `xml:"category"`
+}
+
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+// WriteAtom writes doc as an Atom 1.0 document. Entries without a
+// publication date use the feed's update time, since Atom requires one.
+func WriteAtom(w io.Writer, doc Document) error {
+	out := atomOut{
+		ID:      doc.ID,
+		Title:   doc.Title,
+		Updated: doc.Updated.UTC().Format(time.RFC3339),
+		Links:   []atomLink{{Href: doc.Link, Rel: "alternate", Type: "text/html"}},
+		// Atom needs an author for entries that don't name one
+		Author: atomPerson{Name: "Gator"},
+	}
+	if doc.SelfURL != "" {
+		out.Links = append(out.Links, atomLink{Href: doc.SelfURL, Rel: "self", Type: "application/atom+xml"})
+	}
+	for _, e := range doc.Entries {
+		entry := atomEntry{
+			ID:      e.ID,
+			Title:   e.Title,
+			Link:    atomLink{Href: e.Link, Rel: "alternate"},
+			Updated: out.Updated,
+		}
+		if !e.Published.IsZero() {
+			entry.Published = e.Published.UTC().Format(time.RFC3339)
+			entry.Updated = entry.Published
+		}
+		if e.Author != "" {
+			entry.Author = &atomPerson{Name: e.Author}
+		}
+		if e.Description != "" {
+			entry.Summary = &atomText{Type: "html", Value: e.Description}
+		}
+		for _, c := range e.Categories {
+			entry.Categories = append(entry.Categories, atomCategory{Term: c})
+		}
+		out.Entries = append(out.Entries, entry)
+	}
+	return writeXML(w, out)
+}
+
+func writeXML(w io.Writer, v any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,164 @@
+package rss
+
+import (
+	"bytes"
+	"encoding/xml"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testDocument has an entry with every field set, including a category with
+// a comma in it, and one with only the required fields.
+func testDocument() Document {
+	published := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	return Document{
+		ID:          "urn:uuid:7d7ba9a2-4f0e-5b8e-9a53-3f2d1c1e0b6a",
+		Title:       "Gator: alice",
+		Link:        "https://gator.example.com/",
+		SelfURL:     "https://gator.example.com/api/feed",
+		Description: "Posts from the feeds alice follows in Gator",
+		Updated:     published,
+		Entries: []Entry{
+			{
+				ID:          "urn:uuid:0b6a3f2d-1c1e-4f0e-9a53-7d7ba9a25b8e",
+				Title:       "Cats < Dogs",
+				Link:        "https://example.com/posts/1",
+				Description: "<p>Hello <b>world</b></p>",
+				Author:      "Jane Doe",
+				Published:   published,
+				Categories:  []string{"Go", "Tips, Tricks"},
+			},
+			{
+				ID:    "urn:uuid:5b8e9a53-7d7b-4a9a-2f2d-1c1e0b6a3f2d",
+				Title: "Untitled",
+				Link:  "https://example.com/posts/2",
+			},
+		},
+	}
+}
+
+func TestWriteRSSRoundTrip(t *testing.T) {
+	doc := testDocument()
+	var buf bytes.Buffer
+	if err := WriteRSS(&buf, doc); err != nil {
+		t.Fatal(err)
+	}
+	feed, err := Parse(&buf)
+	if err != nil {
+		t.Fatalf("reading back the written feed: %v", err)
+	}
+
+	ch := feed.Channel
+	if ch.Title != doc.Title || ch.Link != doc.Link || ch.Description != doc.Description {
+		t.Errorf("channel = %q, %q, %q, want %q, %q, %q", ch.Title, ch.Link, ch.Description, doc.Title, doc.Link, doc.Description)
+	}
+	if ch.Self != doc.SelfURL {
+		t.Errorf("self link = %q, want %q", ch.Self, doc.SelfURL)
+	}
+	if len(ch.Item) != len(doc.Entries) {
+		t.Fatalf("got %d items, want %d", len(ch.Item), len(doc.Entries))
+	}
+	for i, want := range doc.Entries {
+		got := ch.Item[i]
+		if got.Title != want.Title || got.Link != want.Link || got.Description != want.Description || got.Author != want.Author {
+			t.Errorf("item %d = %q, %q, %q, %q, want %q, %q, %q, %q", i,
+				got.Title, got.Link, got.Description, got.Author,
+				want.Title, want.Link, want.Description, want.Author)
+		}
+		if !reflect.DeepEqual(got.Categories, want.Categories) {
+			t.Errorf("item %d categories = %q, want %q", i, got.Categories, want.Categories)
+		}
+		if want.Published.IsZero() {
+			if got.PubDate != "" {
+				t.Errorf("item %d pubDate = %q, want none", i, got.PubDate)
+			}
+		} else if published, err := time.Parse(time.RFC1123Z, got.PubDate); err != nil || !published.Equal(want.Published) {
+			t.Errorf("item %d pubDate = %q, want %v", i, got.PubDate, want.Published)
+		}
+	}
+}
+
+// atomIn reads back what WriteAtom writes, independently of its own types.
+type atomIn struct {
+	XMLName xml.Name `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string   `xml:"http://www.w3.org/2005/Atom id"`
+	Title   string   `xml:"http://www.w3.org/2005/Atom title"`
+	Updated string   `xml:"http://www.w3.org/2005/Atom updated"`
+	Links   []struct {
+		Href string `xml:"href,attr"`
+		Rel  string `xml:"rel,attr"`
+	} `xml:"http://www.w3.org/2005/Atom link"`
+	Entries []struct {
+		ID    string `xml:"http://www.w3.org/2005/Atom id"`
+		Title string `xml:"http://www.w3.org/2005/Atom title"`
+		Link  struct {
+			Href string `xml:"href,attr"`
+		} `xml:"http://www.w3.org/2005/Atom link"`
+		Updated   string `xml:"http://www.w3.org/2005/Atom updated"`
+		Published string `xml:"http://www.w3.org/2005/Atom published"`
+		Author    string `xml:"http://www.w3.org/2005/Atom author>name"`
+		Summary   struct {
+			Type  string `xml:"type,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"http://www.w3.org/2005/Atom summary"`
+		Categories []struct {
+			Term string `xml:"term,attr"`
+		} `xml:"http://www.w3.org/2005/Atom category"`
+	} `xml:"http://www.w3.org/2005/Atom entry"`
+}
+
+func TestWriteAtomRoundTrip(t *testing.T) {
+	doc := testDocument()
+	var buf bytes.Buffer
+	if err := WriteAtom(&buf, doc); err != nil {
+		t.Fatal(err)
+	}
+	var feed atomIn
+	if err := xml.Unmarshal(buf.Bytes(), &feed); err != nil {
+		t.Fatalf("reading back the written feed: %v", err)
+	}
+
+	if feed.ID != doc.ID || feed.Title != doc.Title {
+		t.Errorf("feed = %q, %q, want %q, %q", feed.ID, feed.Title, doc.ID, doc.Title)
+	}
+	if updated, err := time.Parse(time.RFC3339, feed.Updated); err != nil || !updated.Equal(doc.Updated) {
+		t.Errorf("updated = %q, want %v", feed.Updated, doc.Updated)
+	}
+	links := make(map[string]string)
+	for _, l := range feed.Links {
+		links[l.Rel] = l.Href
+	}
+	if links["alternate"] != doc.Link || links["self"] != doc.SelfURL {
+		t.Errorf("links = %v, want alternate %q and self %q", links, doc.Link, doc.SelfURL)
+	}
+	if len(feed.Entries) != len(doc.Entries) {
+		t.Fatalf("got %d entries, want %d", len(feed.Entries), len(doc.Entries))
+	}
+	for i, want := range doc.Entries {
+		got := feed.Entries[i]
+		if got.ID != want.ID || got.Title != want.Title || got.Link.Href != want.Link || got.Summary.Value != want.Description || got.Author != want.Author {
+			t.Errorf("entry %d = %q, %q, %q, %q, %q, want %q, %q, %q, %q, %q", i,
+				got.ID, got.Title, got.Link.Href, got.Summary.Value, got.Author,
+				want.ID, want.Title, want.Link, want.Description, want.Author)
+		}
+		if want.Description != "" && got.Summary.Type != "html" {
+			t.Errorf("entry %d summary type = %q, want html", i, got.Summary.Type)
+		}
+		var terms []string
+		for _, c := range got.Categories {
+			terms = append(terms, c.Term)
+		}
+		if !reflect.DeepEqual(terms, want.Categories) {
+			t.Errorf("entry %d categories = %q, want %q", i, terms, want.Categories)
+		}
+		// Atom requires an update time, so undated entries take the feed's
+		wantUpdated := want.Published
+		if wantUpdated.IsZero() {
+			wantUpdated = doc.Updated
+		}
+		if updated, err := time.Parse(time.RFC3339, got.Updated); err != nil || !updated.Equal(wantUpdated) {
+			t.Errorf("entry %d updated = %q, want %v", i, got.Updated, wantUpdated)
+		}
+	}
+}
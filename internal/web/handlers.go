@@ -0,0 +1,291 @@
+package web
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/JadedPigeon/Gator/internal/auth"
+	"github.com/JadedPigeon/Gator/internal/database"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// page holds what the layout needs on every signed-in page.
+type page struct {
+	Title   string
+	User    database.User
+	Follows []database.GetFeedFollowsForUserRow
+	// Next is the current path, posted back by forms to return here.
+	Next string
+}
+
+type loginPage struct {
+	page
+	Name  string
+	Error string
+}
+
+type postsPage struct {
+	page
+	Posts    []database.GetPostsForUserRow
+	Search   string
+	FeedID   string
+	PrevPage int
+	NextPage int
+}
+
+type subscriptionsPage struct {
+	page
+	Feeds []database.GetAllFeedsRow
+	Error string
+}
+
+func (srv *Server) basePage(r *http.Request, user database.User, title string) (page, error) {
+	follows, err := srv.DB.GetFeedFollowsForUser(r.Context(), user.ID)
+	if err != nil {
+		return page{}, err
+	}
+	return page{Title: title, User: user, Follows: follows, Next: r.URL.RequestURI()}, nil
+}
+
+func (srv *Server) handleLoginPage(w http.ResponseWriter, r *http.Request) {
+	srv.render(w, r, "login.html", loginPage{page: page{Title: "Log in"}})
+}
+
+func (srv *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	name := r.FormValue("name")
+	fail := func(msg string) {
+		w.WriteHeader(http.StatusUnauthorized)
+		srv.render(w, r, "login.html", loginPage{page: page{Title: "Log in"}, Name: name, Error: msg})
+	}
+	user, err := srv.DB.GetUser(r.Context(), name)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			fail("Unknown user or wrong password.")
+			return
+		}
+		srv.internalError(w, r, err)
+		return
+	}
+	if !user.PasswordHash.Valid {
+		fail("This user has no password yet. Set one with \"gator passwd\" while logged in as them.")
+		return
+	}
+	ok, err := auth.CheckPassword(user.PasswordHash.String, r.FormValue("password"))
+	if err != nil {
+		srv.internalError(w, r, err)
+		return
+	}
+	if !ok {
+		fail("Unknown user or wrong password.")
+		return
+	}
+	if err := srv.startSession(w, r, user); err != nil {
+		srv.internalError(w, r, err)
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (srv *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		if err := srv.DB.DeleteSession(r.Context(), auth.HashToken(cookie.Value)); err != nil {
+			srv.internalError(w, r, err)
+			return
+		}
+	}
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Path: "/", MaxAge: -1})
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+func (srv *Server) handleTimeline(w http.ResponseWriter, r *http.Request, user database.User) {
+	srv.renderPosts(w, r, user, "Timeline", uuid.NullUUID{})
+}
+
+func (srv *Server) handleFeed(w http.ResponseWriter, r *http.Request, user database.User) {
+	feedID, err := uuid.Parse(r.PathValue("feedID"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	follows, err := srv.DB.GetFeedFollowsForUser(r.Context(), user.ID)
+	if err != nil {
+		srv.internalError(w, r, err)
+		return
+	}
+	for _, f := range follows {
+		if f.FeedID == feedID {
+			srv.renderPosts(w, r, user, f.FeedName, uuid.NullUUID{UUID: feedID, Valid: true})
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
+
+// renderPosts shows one page of posts, optionally limited to a feed and a
+// search from the q parameter.
+func (srv *Server) renderPosts(w http.ResponseWriter, r *http.Request, user database.User, title string, feedID uuid.NullUUID) {
+	pageNum, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || pageNum < 1 {
+		pageNum = 1
+	}
+	search := r.URL.Query().Get("q")
+	posts, err := srv.DB.GetPostsForUser(r.Context(), database.GetPostsForUserParams{
+		UserID:    user.ID,
+		FeedID:    feedID,
+		Search:    sql.NullString{String: search, Valid: search != ""},
+		RowLimit:  pageSize,
+		RowOffset: int32((pageNum - 1) * pageSize),
+	})
+	if err != nil {
+		srv.internalError(w, r, err)
+		return
+	}
+	base, err := srv.basePage(r, user, title)
+	if err != nil {
+		srv.internalError(w, r, err)
+		return
+	}
+	data := postsPage{page: base, Posts: posts, Search: search, PrevPage: pageNum - 1}
+	if feedID.Valid {
+		data.FeedID = feedID.UUID.String()
+	}
+	if len(posts) == pageSize {
+		data.NextPage = pageNum + 1
+	}
+	srv.render(w, r, "posts.html", data)
+}
+
+func (srv *Server) handleRead(w http.ResponseWriter, r *http.Request, user database.User) {
+	postID, err := uuid.Parse(r.PathValue("postID"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	_, err = srv.DB.SetPostRead(r.Context(), database.SetPostReadParams{
+		UserID: user.ID,
+		PostID: postID,
+		Read:   r.FormValue("read") == "true",
+	})
+	if err != nil {
+		srv.internalError(w, r, err)
+		return
+	}
+	redirectBack(w, r)
+}
+
+func (srv *Server) handleStar(w http.ResponseWriter, r *http.Request, user database.User) {
+	postID, err := uuid.Parse(r.PathValue("postID"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	_, err = srv.DB.SetPostStarred(r.Context(), database.SetPostStarredParams{
+		UserID:  user.ID,
+		PostID:  postID,
+		Starred: r.FormValue("starred") == "true",
+	})
+	if err != nil {
+		srv.internalError(w, r, err)
+		return
+	}
+	redirectBack(w, r)
+}
+
+func (srv *Server) handleSubscriptions(w http.ResponseWriter, r *http.Request, user database.User) {
+	srv.renderSubscriptions(w, r, user, "")
+}
+
+func (srv *Server) renderSubscriptions(w http.ResponseWriter, r *http.Request, user database.User, errMsg string) {
+	base, err := srv.basePage(r, user, "Subscriptions")
+	if err != nil {
+		srv.internalError(w, r, err)
+		return
+	}
+	feeds, err := srv.DB.GetAllFeeds(r.Context())
+	if err != nil {
+		srv.internalError(w, r, err)
+		return
+	}
+	base.Next = "/subscriptions"
+	if errMsg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	srv.render(w, r, "subscriptions.html", subscriptionsPage{page: base, Feeds: feeds, Error: errMsg})
+}
+
+func (srv *Server) handleFollow(w http.ResponseWriter, r *http.Request, user database.User) {
+	feed, err := srv.DB.GetFeedByUrl(r.Context(), r.FormValue("url"))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			srv.renderSubscriptions(w, r, user, "That feed does not exist. Add it below.")
+			return
+		}
+		srv.internalError(w, r, err)
+		return
+	}
+	_, err = srv.DB.CreateFeedFollow(r.Context(), database.CreateFeedFollowParams{
+		FeedID: feed.ID,
+		UserID: user.ID,
+	})
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			srv.renderSubscriptions(w, r, user, "You already follow that feed.")
+			return
+		}
+		srv.internalError(w, r, err)
+		return
+	}
+	http.Redirect(w, r, "/subscriptions", http.StatusSeeOther)
+}
+
+func (srv *Server) handleAddFeed(w http.ResponseWriter, r *http.Request, user database.User) {
+	name, feedURL := r.FormValue("name"), r.FormValue("url")
+	if name == "" || feedURL == "" {
+		srv.renderSubscriptions(w, r, user, "A new feed needs a name and a URL.")
+		return
+	}
+	feed, err := srv.DB.CreateFeed(r.Context(), database.CreateFeedParams{
+		Name:   name,
+		Url:    feedURL,
+		UserID: user.ID,
+	})
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			srv.renderSubscriptions(w, r, user, "A feed with that URL already exists. Follow it from the list above.")
+			return
+		}
+		srv.internalError(w, r, err)
+		return
+	}
+	_, err = srv.DB.CreateFeedFollow(r.Context(), database.CreateFeedFollowParams{
+		FeedID: feed.ID,
+		UserID: user.ID,
+	})
+	if err != nil {
+		srv.internalError(w, r, err)
+		return
+	}
+	http.Redirect(w, r, "/subscriptions", http.StatusSeeOther)
+}
+
+func (srv *Server) handleUnfollow(w http.ResponseWriter, r *http.Request, user database.User) {
+	feedID, err := uuid.Parse(r.PathValue("feedID"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	_, err = srv.DB.DeleteFeedFollow(r.Context(), database.DeleteFeedFollowParams{
+		FeedID: feedID,
+		UserID: user.ID,
+	})
+	if err != nil {
+		srv.internalError(w, r, err)
+		return
+	}
+	http.Redirect(w, r, "/subscriptions", http.StatusSeeOther)
+}
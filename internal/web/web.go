@@ -0,0 +1,184 @@
+// Package web serves a browser-based reader on top of the same queries the
+// CLI uses.
+package web
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/JadedPigeon/Gator/internal/auth"
+	"github.com/JadedPigeon/Gator/internal/database"
+	"github.com/JadedPigeon/Gator/internal/rss"
+)
+
+const (
+	sessionCookie   = "gator_session"
+	sessionLifetime = 30 * 24 * time.Hour
+	pageSize        = 20
+)
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+var pages = parsePages("login.html", "posts.html", "subscriptions.html")
+
+// parsePages parses each page together with the shared layout. Pages are
+// parsed separately because they all define the same "content" block.
+func parsePages(names ...string) map[string]*template.Template {
+	funcs := template.FuncMap{
+		"excerpt": excerpt,
+		"date":    formatDate,
+		"join":    strings.Join,
+	}
+	m := make(map[string]*template.Template, len(names))
+	for _, name := range names {
+		m[name] = template.Must(template.New(name).Funcs(funcs).ParseFS(templateFS, "templates/layout.html", "templates/"+name))
+	}
+	return m
+}
+
+type Server struct {
+	DB  *database.Queries
+	Log *slog.Logger
+}
+
+// logger returns Log, or the default logger if it isn't set.
+func (srv *Server) logger() *slog.Logger {
+	if srv.Log != nil {
+		return srv.Log
+	}
+	return slog.Default()
+}
+
+func (srv *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /login", srv.handleLoginPage)
+	mux.HandleFunc("POST /login", srv.handleLogin)
+	mux.HandleFunc("POST /logout", srv.handleLogout)
+	mux.HandleFunc("GET /{$}", srv.withUser(srv.handleTimeline))
+	mux.HandleFunc("GET /feeds/{feedID}", srv.withUser(srv.handleFeed))
+	mux.HandleFunc("POST /posts/{postID}/read", srv.withUser(srv.handleRead))
+	mux.HandleFunc("POST /posts/{postID}/star", srv.withUser(srv.handleStar))
+	mux.HandleFunc("GET /subscriptions", srv.withUser(srv.handleSubscriptions))
+	mux.HandleFunc("POST /subscriptions", srv.withUser(srv.handleFollow))
+	mux.HandleFunc("POST /subscriptions/new", srv.withUser(srv.handleAddFeed))
+	mux.HandleFunc("POST /subscriptions/{feedID}/unfollow", srv.withUser(srv.handleUnfollow))
+	return sameOrigin(mux)
+}
+
+type userHandler func(w http.ResponseWriter, r *http.Request, user database.User)
+
+// withUser looks up the session cookie and sends visitors without a valid
+// session to the login page.
+func (srv *Server) withUser(h userHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie(sessionCookie)
+		if err != nil {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+		user, err := srv.DB.GetUserBySession(r.Context(), auth.HashToken(cookie.Value))
+		if err != nil {
+			if err == sql.ErrNoRows {
+				http.Redirect(w, r, "/login", http.StatusSeeOther)
+				return
+			}
+			srv.internalError(w, r, err)
+			return
+		}
+		h(w, r, user)
+	}
+}
+
+// startSession creates a session for user and sets its cookie.
+func (srv *Server) startSession(w http.ResponseWriter, r *http.Request, user database.User) error {
+	// Piggyback cleanup on logins rather than running a timer
+	if err := srv.DB.DeleteExpiredSessions(r.Context()); err != nil {
+		return err
+	}
+	token, err := auth.NewToken()
+	if err != nil {
+		return err
+	}
+	expires := time.Now().Add(sessionLifetime)
+	_, err = srv.DB.CreateSession(r.Context(), database.CreateSessionParams{
+		UserID:    user.ID,
+		TokenHash: auth.HashToken(token),
+		ExpiresAt: expires,
+	})
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+// sameOrigin rejects cross-site form posts, so another site can't act with
+// the user's session cookie.
+func sameOrigin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			if origin := r.Header.Get("Origin"); origin != "" {
+				u, err := url.Parse(origin)
+				if err != nil || u.Host != r.Host {
+					http.Error(w, "cross-origin request refused", http.StatusForbidden)
+					return
+				}
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (srv *Server) render(w http.ResponseWriter, r *http.Request, name string, data any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := pages[name].ExecuteTemplate(w, "layout", data); err != nil {
+		srv.logger().Error("Web error", "template", name, "error", err)
+	}
+}
+
+func (srv *Server) internalError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(r.Context().Err(), context.Canceled) {
+		return
+	}
+	srv.logger().Error("Web error", "method", r.Method, "path", r.URL.Path, "error", err)
+	http.Error(w, "Something went wrong.", http.StatusInternalServerError)
+}
+
+// redirectBack returns to the page a form was posted from, falling back to
+// the timeline. Only local paths are followed.
+func redirectBack(w http.ResponseWriter, r *http.Request) {
+	next := r.FormValue("next")
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		next = "/"
+	}
+	http.Redirect(w, r, next, http.StatusSeeOther)
+}
+
+// excerpt turns an HTML description into a short plain-text preview.
+func excerpt(s string) string {
+	return rss.Excerpt(s, 300)
+}
+
+func formatDate(t sql.NullTime) string {
+	if !t.Valid {
+		return ""
+	}
+	return t.Time.Local().Format("2 Jan 2006 15:04")
+}
@@ -0,0 +1,165 @@
+// Package webhook renders, signs and sends notifications about new posts.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	// EventNewPost is the only event so far.
+	EventNewPost = "new_post"
+	// MaxAttempts is how many times a delivery is tried before giving up.
+	MaxAttempts = 8
+
+	firstRetry = 30 * time.Second
+	maxRetry   = time.Hour
+)
+
+// Payload is what a webhook receives: sent as JSON by default, or given to
+// the webhook's template.
+type Payload struct {
+	Event string `json:"event"`
+	Feed  Feed   `json:"feed"`
+	Post  Post   `json:"post"`
+}
+
+type Feed struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+type Post struct {
+	ID          string     `json:"id"`
+	Title       string     `json:"title"`
+	URL         string     `json:"url"`
+	Description string     `json:"description,omitempty"`
+	Author      string     `json:"author,omitempty"`
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+}
+
+// Templates get a json function, which quotes a value for use inside a
+// JSON body, e.g. {"text": {{json .Post.Title}}}.
+var funcs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// ParseTemplate parses a payload template and tries it on an example post,
+// so mistakes such as unknown fields show up before any delivery.
+func ParseTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("payload").Funcs(funcs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid payload template: %v", err)
+	}
+	example := Payload{
+		Event: EventNewPost,
+		Feed:  Feed{Name: "Example", URL: "https://example.com/feed.xml"},
+		Post:  Post{ID: "00000000-0000-0000-0000-000000000000", Title: "Example post", URL: "https://example.com/post"},
+	}
+	if err := tmpl.Execute(io.Discard, example); err != nil {
+		return nil, fmt.Errorf("invalid payload template: %v", err)
+	}
+	return tmpl, nil
+}
+
+// Render builds the request body for p, as JSON when tmpl is empty.
+func Render(tmpl string, p Payload) ([]byte, error) {
+	if tmpl == "" {
+		return json.Marshal(p)
+	}
+	t, err := template.New("payload").Funcs(funcs).Parse(tmpl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid payload template: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, p); err != nil {
+		return nil, fmt.Errorf("error rendering payload: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// NewSecret returns a random key for signing a webhook's payloads.
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Sign returns the X-Gator-Signature header for body: "sha256=" and the
+// hex HMAC-SHA256 of the body under secret.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Backoff is how long to wait after the given number of failed attempts:
+// 30s, 1m, 2m and so on, up to an hour.
+func Backoff(failures int) time.Duration {
+	d := firstRetry
+	for i := 1; i < failures && d < maxRetry; i++ {
+		d *= 2
+	}
+	return min(d, maxRetry)
+}
+
+// Client sends webhook requests; *rss.Fetcher implements it.
+type Client interface {
+	Send(*http.Request) (*http.Response, error)
+}
+
+// ContentType is the Content-Type header for a webhook's requests: the one
+// the webhook chose, else JSON for the default body. A template's output
+// could be anything, so it gets no header unless the webhook sets one.
+func ContentType(chosen, tmpl string) string {
+	if chosen != "" {
+		return chosen
+	}
+	if tmpl == "" {
+		return "application/json"
+	}
+	return ""
+}
+
+// Send posts body to url, with a Content-Type header unless contentType is
+// empty. It returns the response status, if there was a response, and an
+// error unless the status was 2xx.
+func Send(ctx context.Context, client Client, url, contentType, secret, deliveryID string, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("X-Gator-Event", EventNewPost)
+	req.Header.Set("X-Gator-Delivery", deliveryID)
+	req.Header.Set("X-Gator-Signature", Sign(secret, body))
+	resp, err := client.Send(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		return resp.StatusCode, fmt.Errorf("unexpected HTTP status: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	return resp.StatusCode, nil
+}
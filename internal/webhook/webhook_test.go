@@ -0,0 +1,164 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 30 * time.Second},
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{4, 4 * time.Minute},
+		{7, 32 * time.Minute},
+		{8, time.Hour},
+		{100, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := Backoff(tt.failures); got != tt.want {
+			t.Errorf("Backoff(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestSign(t *testing.T) {
+	// HMAC-SHA256 test case 2 from RFC 4231
+	got := Sign("Jefe", []byte("what do ya want for nothing?"))
+	want := "sha256=5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"
+	if got != want {
+		t.Errorf("Sign = %q, want %q", got, want)
+	}
+}
+
+func testPayload() Payload {
+	published := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	return Payload{
+		Event: EventNewPost,
+		Feed:  Feed{Name: "Blog", URL: "https://example.com/feed.xml"},
+		Post: Post{
+			ID:          "7d7ba9a2-4f0e-4b8e-9a53-3f2d1c1e0b6a",
+			Title:       `Say "hi"`,
+			URL:         "https://example.com/hi",
+			PublishedAt: &published,
+		},
+	}
+}
+
+func TestRenderDefault(t *testing.T) {
+	body, err := Render("", testPayload())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Payload
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatalf("default body is not JSON: %v: %s", err, body)
+	}
+	want := testPayload()
+	if got.Event != want.Event || got.Feed != want.Feed || got.Post.Title != want.Post.Title || !got.Post.PublishedAt.Equal(*want.Post.PublishedAt) {
+		t.Errorf("body = %+v, want %+v", got, want)
+	}
+	if strings.Contains(string(body), `"description"`) || strings.Contains(string(body), `"author"`) {
+		t.Errorf("body has empty optional fields: %s", body)
+	}
+}
+
+func TestRenderTemplate(t *testing.T) {
+	body, err := Render(`{"text": {{json .Post.Title}}, "feed": "{{.Feed.Name}}"}`, testPayload())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"text": "Say \"hi\"", "feed": "Blog"}`; string(body) != want {
+		t.Errorf("body = %s, want %s", body, want)
+	}
+}
+
+func TestParseTemplate(t *testing.T) {
+	if _, err := ParseTemplate(`{{json .Post.Title}} {{.Feed.URL}}`); err != nil {
+		t.Errorf("valid template: %v", err)
+	}
+	for _, text := range []string{
+		`{{.Post.Title`,
+		`{{.Post.Nope}}`,
+		`{{nope .Post.Title}}`,
+	} {
+		if _, err := ParseTemplate(text); err == nil {
+			t.Errorf("ParseTemplate(%q) succeeded, want an error", text)
+		}
+	}
+}
+
+// httpClient sends webhook requests with the default HTTP client.
+type httpClient struct{}
+
+func (httpClient) Send(req *http.Request) (*http.Response, error) {
+	return http.DefaultClient.Do(req)
+}
+
+func TestSend(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		status      int
+		wantErr     bool
+	}{
+		{"json", "application/json", http.StatusOK, false},
+		{"no content type", "", http.StatusNoContent, false},
+		{"server error", "text/plain", http.StatusBadGateway, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *http.Request
+			var gotBody []byte
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r
+				gotBody, _ = io.ReadAll(r.Body)
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			body := []byte("hello")
+			status, err := Send(context.Background(), httpClient{}, srv.URL, tt.contentType, "secret", "delivery-1", body)
+			if status != tt.status || (err != nil) != tt.wantErr {
+				t.Fatalf("Send = %d, %v, want %d and error %v", status, err, tt.status, tt.wantErr)
+			}
+			if ct, ok := got.Header["Content-Type"]; tt.contentType == "" && ok {
+				t.Errorf("Content-Type = %q, want none", ct)
+			} else if tt.contentType != "" && got.Header.Get("Content-Type") != tt.contentType {
+				t.Errorf("Content-Type = %q, want %q", got.Header.Get("Content-Type"), tt.contentType)
+			}
+			if got.Header.Get("X-Gator-Signature") != Sign("secret", body) || got.Header.Get("X-Gator-Delivery") != "delivery-1" {
+				t.Errorf("headers = %v", got.Header)
+			}
+			if string(gotBody) != "hello" {
+				t.Errorf("body = %q, want hello", gotBody)
+			}
+		})
+	}
+}
+
+func TestContentType(t *testing.T) {
+	tests := []struct {
+		chosen, tmpl, want string
+	}{
+		{"", "", "application/json"},
+		{"", "text={{.Post.Title}}", ""},
+		{"application/x-www-form-urlencoded", "text={{.Post.Title}}", "application/x-www-form-urlencoded"},
+		{"application/vnd.example+json", "", "application/vnd.example+json"},
+	}
+	for _, tt := range tests {
+		if got := ContentType(tt.chosen, tt.tmpl); got != tt.want {
+			t.Errorf("ContentType(%q, %q) = %q, want %q", tt.chosen, tt.tmpl, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+// Package websub subscribes to feeds through WebSub hubs and receives the
+// content they push, so new posts show up without waiting for agg to poll.
+package websub
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/JadedPigeon/Gator/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	// LeaseSeconds is the lease asked for; hubs may grant less.
+	LeaseSeconds = 7 * 24 * 60 * 60
+	// defaultMaxBodySize matches the limit on fetched feeds.
+	defaultMaxBodySize = 32 << 20
+)
+
+// Client sends requests to hubs; *rss.Fetcher implements it.
+type Client interface {
+	Send(*http.Request) (*http.Response, error)
+}
+
+// NewSecret returns a random secret for hubs to sign pushed content with.
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// CallbackURL is where the hub reaches subscription id, under base, the
+// public address of the serve command.
+func CallbackURL(base string, id uuid.UUID) string {
+	return strings.TrimRight(base, "/") + "/websub/" + id.String()
+}
+
+// Subscribe asks hub to push topic to callback. Hubs answer 202 Accepted
+// and confirm later by calling the callback, which Server handles.
+func Subscribe(ctx context.Context, client Client, hub, topic, callback, secret string) error {
+	form := url.Values{
+		"hub.mode":          {"subscribe"},
+		"hub.topic":         {topic},
+		"hub.callback":      {callback},
+		"hub.secret":        {secret},
+		"hub.lease_seconds": {strconv.Itoa(LeaseSeconds)},
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", hub, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := client.Send(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		return fmt.Errorf("hub refused subscription: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// VerifySignature checks an X-Hub-Signature header, "method=hexdigest",
+// against an HMAC of body.
+func VerifySignature(secret, header string, body []byte) bool {
+	method, sig, ok := strings.Cut(header, "=")
+	if !ok {
+		return false
+	}
+	var h func() hash.Hash
+	switch strings.ToLower(method) {
+	case "sha1":
+		h = sha1.New
+	case "sha256":
+		h = sha256.New
+	case "sha384":
+		h = sha512.New384
+	case "sha512":
+		h = sha512.New
+	default:
+		return false
+	}
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(h, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// Server handles hub callbacks at /websub/{id}: intent verification on GET
+// and pushed content on POST.
+type Server struct {
+	DB *database.Queries
+	// Ingest stores pushed content the same way agg stores a fetched feed.
+	Ingest      func(ctx context.Context, sub database.WebsubSubscription, body io.Reader) error
+	MaxBodySize int64
+	Log         *slog.Logger
+}
+
+// logger returns Log, or the default logger if it isn't set.
+func (srv *Server) logger() *slog.Logger {
+	if srv.Log != nil {
+		return srv.Log
+	}
+	return slog.Default()
+}
+
+func (srv *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /websub/{id}", srv.handleVerify)
+	mux.HandleFunc("POST /websub/{id}", srv.handlePush)
+	return mux
+}
+
+// subscription loads the subscription named in the path, writing a 404 if
+// there isn't one.
+func (srv *Server) subscription(w http.ResponseWriter, r *http.Request) (database.WebsubSubscription, bool) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return database.WebsubSubscription{}, false
+	}
+	sub, err := srv.DB.GetWebSubSubscription(r.Context(), id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.NotFound(w, r)
+		} else {
+			srv.serverError(w, r, err)
+		}
+		return database.WebsubSubscription{}, false
+	}
+	return sub, true
+}
+
+func (srv *Server) handleVerify(w http.ResponseWriter, r *http.Request) {
+	sub, ok := srv.subscription(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	if q.Get("hub.topic") != sub.TopicUrl {
+		http.NotFound(w, r)
+		return
+	}
+	switch q.Get("hub.mode") {
+	case "subscribe":
+		challenge := q.Get("hub.challenge")
+		if challenge == "" || sub.State == "denied" {
+			http.NotFound(w, r)
+			return
+		}
+		// A lease far longer than asked for is capped
+		lease, err := strconv.Atoi(q.Get("hub.lease_seconds"))
+		if err != nil || lease <= 0 {
+			lease = LeaseSeconds
+		}
+		lease = min(lease, LeaseSeconds*4)
+		err = srv.DB.ActivateWebSubSubscription(r.Context(), database.ActivateWebSubSubscriptionParams{
+			LeaseSeconds: int32(lease),
+			ID:           sub.ID,
+		})
+		if err != nil {
+			srv.serverError(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, challenge)
+	case "denied":
+		reason := q.Get("hub.reason")
+		if reason == "" {
+			reason = "denied by hub"
+		}
+		err := srv.DB.DenyWebSubSubscription(r.Context(), database.DenyWebSubSubscriptionParams{
+			ID:        sub.ID,
+			LastError: sql.NullString{String: reason, Valid: true},
+		})
+		if err != nil {
+			srv.serverError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	default:
+		// Gator never asks to unsubscribe, so it doesn't confirm it either
+		http.NotFound(w, r)
+	}
+}
+
+func (srv *Server) handlePush(w http.ResponseWriter, r *http.Request) {
+	sub, ok := srv.subscription(w, r)
+	if !ok {
+		return
+	}
+	maxBody := srv.MaxBodySize
+	if maxBody <= 0 {
+		maxBody = defaultMaxBodySize
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBody))
+	if err != nil {
+		http.Error(w, "body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	// The spec asks for a 2xx even when the signature is wrong, so a
+	// forger learns nothing; the content is dropped all the same
+	if !VerifySignature(sub.Secret, r.Header.Get("X-Hub-Signature"), body) {
+		srv.logger().Warn("Ignoring WebSub push with a missing or bad signature", "feed_id", sub.FeedID, "feed_url", sub.TopicUrl)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	if err := srv.Ingest(r.Context(), sub, bytes.NewReader(body)); err != nil {
+		// A 5xx makes the hub retry later
+		srv.serverError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (srv *Server) serverError(w http.ResponseWriter, r *http.Request, err error) {
+	srv.logger().Error("WebSub error", "method", r.Method, "path", r.URL.Path, "error", err)
+	http.Error(w, "internal server error", http.StatusInternalServerError)
+}
@@ -0,0 +1,241 @@
+package websub
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JadedPigeon/Gator/internal/database"
+	"github.com/JadedPigeon/Gator/internal/dbtest"
+	"github.com/google/uuid"
+)
+
+// httpClient adapts http.Client to Client.
+type httpClient struct{ *http.Client }
+
+func (c httpClient) Send(req *http.Request) (*http.Response, error) { return c.Do(req) }
+
+// hub is a local stand-in for a WebSub hub. It accepts subscription
+// requests and keeps the last one so the test can act on it.
+type hub struct {
+	mu      sync.Mutex
+	request url.Values
+	status  int
+}
+
+func newHub(t *testing.T, status int) (*hub, *httptest.Server) {
+	t.Helper()
+	h := &hub{status: status}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.Header.Get("Content-Type") != "application/x-www-form-urlencoded" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		r.ParseForm()
+		h.mu.Lock()
+		h.request = r.PostForm
+		h.mu.Unlock()
+		w.WriteHeader(h.status)
+		io.WriteString(w, "hub says no\n")
+	}))
+	t.Cleanup(srv.Close)
+	return h, srv
+}
+
+// last returns the most recent subscription request.
+func (h *hub) last() url.Values {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.request
+}
+
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestSubscribeSendsRequest(t *testing.T) {
+	h, hubSrv := newHub(t, http.StatusAccepted)
+	err := Subscribe(context.Background(), httpClient{hubSrv.Client()}, hubSrv.URL, "https://example.com/feed", "https://gator.example.com/websub/1", "s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"hub.mode":          "subscribe",
+		"hub.topic":         "https://example.com/feed",
+		"hub.callback":      "https://gator.example.com/websub/1",
+		"hub.secret":        "s3cret",
+		"hub.lease_seconds": strconv.Itoa(LeaseSeconds),
+	}
+	for k, v := range want {
+		if got := h.last().Get(k); got != v {
+			t.Errorf("%s = %q, want %q", k, got, v)
+		}
+	}
+}
+
+func TestSubscribeRefused(t *testing.T) {
+	_, hubSrv := newHub(t, http.StatusForbidden)
+	err := Subscribe(context.Background(), httpClient{hubSrv.Client()}, hubSrv.URL, "https://example.com/feed", "https://gator.example.com/websub/1", "s3cret")
+	if err == nil || !strings.Contains(err.Error(), "hub says no") {
+		t.Errorf("got %v, want an error quoting the hub", err)
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	body := []byte("<rss/>")
+	good := sign("s3cret", body)
+	tests := []struct {
+		name   string
+		header string
+		want   bool
+	}{
+		{"sha256", good, true},
+		{"upper-case method", "SHA256=" + strings.TrimPrefix(good, "sha256="), true},
+		{"wrong secret", sign("other", body), false},
+		{"unknown method", "md5=" + strings.TrimPrefix(good, "sha256="), false},
+		{"not hex", "sha256=zz", false},
+		{"missing", "", false},
+	}
+	for _, tt := range tests {
+		if got := VerifySignature("s3cret", tt.header, body); got != tt.want {
+			t.Errorf("%s: VerifySignature = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHubRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	_, db := dbtest.Queries(t)
+	now := time.Now()
+	user, err := db.CreateUser(ctx, database.CreateUserParams{ID: uuid.New(), CreatedAt: now, UpdatedAt: now, Name: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	topic := "https://example.com/feed.xml"
+	feed, err := db.CreateFeed(ctx, database.CreateFeedParams{Name: "Blog", Url: topic, UserID: user.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, err := NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, hubSrv := newHub(t, http.StatusAccepted)
+	sub, err := db.RequestWebSubSubscription(ctx, database.RequestWebSubSubscriptionParams{
+		FeedID: feed.ID, HubUrl: hubSrv.URL, TopicUrl: topic, Secret: secret,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var pushed []string
+	gator := httptest.NewServer((&Server{
+		DB: db,
+		Ingest: func(ctx context.Context, sub database.WebsubSubscription, body io.Reader) error {
+			b, err := io.ReadAll(body)
+			mu.Lock()
+			pushed = append(pushed, string(b))
+			mu.Unlock()
+			return err
+		},
+	}).Handler())
+	defer gator.Close()
+
+	callback := CallbackURL(gator.URL+"/", sub.ID)
+	if err := Subscribe(ctx, httpClient{hubSrv.Client()}, hubSrv.URL, topic, callback, secret); err != nil {
+		t.Fatal(err)
+	}
+	if got := h.last().Get("hub.callback"); got != callback {
+		t.Fatalf("hub got callback %q, want %q", got, callback)
+	}
+
+	// The hub verifies intent by asking the callback to echo a challenge
+	verify := func(topic, challenge string) (int, string) {
+		t.Helper()
+		q := url.Values{
+			"hub.mode":          {"subscribe"},
+			"hub.topic":         {topic},
+			"hub.challenge":     {challenge},
+			"hub.lease_seconds": {"3600"},
+		}
+		resp, err := http.Get(callback + "?" + q.Encode())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+	if status, _ := verify("https://example.com/other.xml", "abc"); status != http.StatusNotFound {
+		t.Errorf("topic mismatch: status %d, want 404", status)
+	}
+	if got, _ := db.GetWebSubSubscription(ctx, sub.ID); got.State != "pending" {
+		t.Errorf("after a topic mismatch, state = %q, want pending", got.State)
+	}
+	status, body := verify(topic, "challenge-123")
+	if status != http.StatusOK || body != "challenge-123" {
+		t.Errorf("verification: got %d %q, want 200 echoing the challenge", status, body)
+	}
+	got, err := db.GetWebSubSubscription(ctx, sub.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.State != "active" || !got.LeaseExpiresAt.Valid {
+		t.Errorf("after verification: state %q, lease %v; want active with a lease", got.State, got.LeaseExpiresAt)
+	}
+
+	// Pushed content is only stored when it carries the right signature
+	push := func(content, signature string) int {
+		t.Helper()
+		req, _ := http.NewRequest("POST", callback, strings.NewReader(content))
+		req.Header.Set("Content-Type", "application/rss+xml")
+		if signature != "" {
+			req.Header.Set("X-Hub-Signature", signature)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	good := `<rss><channel><title>Blog</title></channel></rss>`
+	if status := push(good, sign(secret, []byte(good))); status != http.StatusAccepted {
+		t.Errorf("signed push: status %d, want 202", status)
+	}
+	forged := `<rss><channel><title>Forged</title></channel></rss>`
+	if status := push(forged, sign("not-the-secret", []byte(forged))); status != http.StatusAccepted {
+		t.Errorf("badly signed push: status %d, want 202 all the same", status)
+	}
+	if status := push(forged, ""); status != http.StatusAccepted {
+		t.Errorf("unsigned push: status %d, want 202 all the same", status)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(pushed) != 1 || pushed[0] != good {
+		t.Errorf("ingested %q, want only the signed push", pushed)
+	}
+
+	// Unknown subscriptions are not found
+	resp, err := http.Get(CallbackURL(gator.URL, uuid.New()) + "?hub.mode=subscribe&hub.challenge=x&hub.topic=" + url.QueryEscape(topic))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown subscription: status %d, want 404", resp.StatusCode)
+	}
+}
@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"database/sql"
 
@@ -15,14 +19,55 @@ import (
 )
 
 func main() {
-	// Load the config from disk
-	cfg, err := config.Read()
+	// Global flags come before the command name, e.g. gator --output json users
+	globalFlags := flag.NewFlagSet("gator", flag.ExitOnError)
+	outputFlag := globalFlags.String("output", string(cli.OutputTable), "output format for list commands: table, json, jsonl or csv")
+	logLevelFlag := globalFlags.String("log-level", "", "least severe log messages to show: debug, info, warn or error (default info)")
+	logFormatFlag := globalFlags.String("log-format", "", "log format: text or json (default text)")
+	globalFlags.Parse(os.Args[1:])
+	format, err := cli.ParseOutputFormat(*outputFlag)
 	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Create the command registry with every built-in command
+	cmds := cli.NewCommands()
+	cmds.Global = globalFlags
+
+	// Whatever follows the global flags is the command and its arguments
+	args := globalFlags.Args()
+	if len(args) < 1 {
+		cmds.PrintHelp(os.Stderr)
+		os.Exit(1)
+	}
+	cmd := args[0]
+	cmdArgs := args[1:]
+
+	// Load the config from disk; help and completion work without one
+	cfg, err := config.Read()
+	if err != nil && !cmds.Info[cmd].Standalone {
 		log.Fatal("error reading config:", err)
 	}
 	// fmt.Println("Current DB URL:", cfg.DBURL)
 	// fmt.Println("Current User:", cfg.CurrentUser)
 
+	// Diagnostics go to stderr so they never mix with command output
+	logLevel, logFormat := cfg.LogLevel, cfg.LogFormat
+	if *logLevelFlag != "" {
+		logLevel = *logLevelFlag
+	}
+	if *logFormatFlag != "" {
+		logFormat = *logFormatFlag
+	}
+	level, err := cli.ParseLogLevel(logLevel)
+	if err != nil {
+		log.Fatal(err)
+	}
+	logger, err := cli.NewLogger(os.Stderr, logFormat, level)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	// Open the DB connection using the config's DB URL
 	db, err := sql.Open("postgres", cfg.DBURL)
 	if err != nil {
@@ -34,34 +79,16 @@ func main() {
 
 	// Store both the DB and config in the state
 	s := &cli.State{
-		Cfg: &cfg,
-		DB:  dbQueries,
-	}
-	// Create a new commands structure and initialize it
-	cmds := cli.Commands{
-		Handlers: make(map[string]func(*cli.State, cli.Command) error),
-	}
+		Cfg:    &cfg,
+		DB:     dbQueries,
+		Conn:   db,
+		Format: format,
+		Out:    os.Stdout,
+		Log:    logger,
 
-	// Register a handler function for the login command
-	cmds.Register("login", cli.HandlerLogin)
-	cmds.Register("register", cli.HandlerRegister)
-	cmds.Register("reset", cli.HandlerReset)
-	cmds.Register("users", cli.HandlerUsers)
-	cmds.Register("agg", cli.HandlerAgg)
-	cmds.Register("addfeed", cli.MiddlewareLoggedIn(cli.HandlerAddFeeds))
-	cmds.Register("feeds", cli.HandlerFeeds)
-	cmds.Register("follow", cli.MiddlewareLoggedIn(cli.HandlerFollow))
-	cmds.Register("following", cli.MiddlewareLoggedIn(cli.HandlerFollowing))
-	cmds.Register("unfollow", cli.MiddlewareLoggedIn(cli.HandlerUnfollow))
-	cmds.Register("browse", cli.MiddlewareLoggedIn(cli.HandlerBrowse))
-
-	// Use os.Args to get the command-line arguments passed in by the user
-	if len(os.Args) < 2 {
-		log.Fatal("no command provided, please specify a command")
+		LogLevel:  level,
+		LogFormat: logFormat,
 	}
-	args := os.Args[1:]
-	cmd := args[0]
-	cmdArgs := args[1:]
 
 	// Create a Command struct with the command name and arguments
 	command := cli.Command{
@@ -69,12 +96,21 @@ func main() {
 		Args: cmdArgs,
 	}
 
+	// The root context is canceled on Ctrl+C or SIGTERM, which stops any
+	// in-flight database call or fetch
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// Run the command using the registered handlers
-	commandErr := cmds.Run(s, command)
+	commandErr := cmds.Run(ctx, s, command)
 	if commandErr != nil {
 		log.Fatalf("error running command '%s': %v", command.Name, commandErr)
 	}
-	fmt.Println("Command executed successfully.")
+	// Keep structured output and generated scripts clean for whatever is
+	// reading them
+	if info := cmds.Info[command.Name]; format == cli.OutputTable && !info.Standalone && !info.Document {
+		fmt.Println("Command executed successfully.")
+	}
 
 	// // Database setup
 	// sql.Open("postgres", cfg.DBURL)
@@ -0,0 +1,17 @@
+-- name: GetAlertPost :one
+-- Everything an alert hook is told about a post, as the given user sees it.
+SELECT
+    users.name AS user_name,
+    COALESCE(ff.alias, f.name) AS feed_name,
+    f.url AS feed_url,
+    p.id,
+    p.title,
+    p.url,
+    p.description,
+    p.author,
+    p.published_at
+FROM posts p
+JOIN feeds f ON f.id = p.feed_id
+JOIN users ON users.id = @user_id
+LEFT JOIN feed_follows ff ON ff.feed_id = f.id AND ff.user_id = users.id
+WHERE p.id = @post_id;
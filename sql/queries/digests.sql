@@ -0,0 +1,67 @@
+-- name: DeleteDigest :execrows
+DELETE FROM digests
+WHERE user_id = $1;
+
+-- name: GetDigestPosts :many
+-- Unread posts that no digest has included yet, going back to the day
+-- before digests were turned on.
+SELECT
+    p.id,
+    p.title,
+    p.url,
+    p.description,
+    p.published_at,
+    COALESCE(ff.alias, f.name) AS feed_name
+FROM digests d
+JOIN feed_follows ff ON ff.user_id = d.user_id
+JOIN feeds f ON f.id = ff.feed_id
+JOIN posts p ON p.feed_id = f.id
+LEFT JOIN post_states ps ON ps.post_id = p.id AND ps.user_id = d.user_id
+WHERE d.user_id = $1
+  AND p.created_at > d.created_at - INTERVAL '1 day'
+  AND NOT EXISTS (
+      SELECT 1 FROM digest_posts dp
+      WHERE dp.user_id = d.user_id AND dp.post_id = p.id
+  )
+  AND COALESCE(ps.read, false) = false
+  AND COALESCE(ps.hidden, false) = false
+ORDER BY feed_name, p.published_at DESC NULLS LAST
+LIMIT $2;
+
+-- name: GetDueDigests :many
+SELECT
+    d.user_id,
+    users.name AS user_name,
+    d.email,
+    d.last_digest_at
+FROM digests d
+JOIN users ON users.id = d.user_id
+WHERE d.last_digest_at IS NULL
+   OR d.last_digest_at <= NOW() - make_interval(secs => @interval_seconds::int)
+ORDER BY users.name;
+
+-- name: MarkDigestSent :exec
+-- Records the posts a digest included and when it was sent.
+WITH sent AS (
+    INSERT INTO digest_posts (user_id, post_id)
+    SELECT @user_id::uuid, unnest(@post_ids::uuid[])
+    ON CONFLICT (user_id, post_id) DO NOTHING
+)
+UPDATE digests
+SET last_digest_at = NOW()
+WHERE user_id = @user_id::uuid;
+
+-- name: SetDigestEmail :exec
+INSERT INTO digests (user_id, email)
+VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE
+SET email = EXCLUDED.email;
+
+-- name: LockDueDigest :one
+-- Locks a digest that is still due for the rest of the transaction. No
+-- row means another aggregator is sending it or already has.
+SELECT user_id FROM digests
+WHERE user_id = @user_id
+  AND (last_digest_at IS NULL
+       OR last_digest_at <= NOW() - make_interval(secs => @interval_seconds::int))
+FOR UPDATE SKIP LOCKED;
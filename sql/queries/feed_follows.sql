@@ -15,7 +15,7 @@ SELECT
     feed_follows.created_at,
     feed_follows.updated_at,
     feeds.id AS feed_id,
-    feeds.name AS feed_name,
+    COALESCE(feed_follows.alias, feeds.name) AS feed_name,
     feeds.url AS feed_url,
     users.id AS user_id,
     users.name AS user_name
@@ -25,6 +25,18 @@ INNER JOIN users ON feed_follows.user_id = users.id
 WHERE feed_follows.user_id = $1
 ORDER BY feed_follows.created_at DESC;
 
--- name: DeleteFeedFollow :exec
+-- name: DeleteFeedFollow :execrows
   DELETE FROM feed_follows
   WHERE feed_id = $1 AND user_id = $2;
+
+-- name: IsFollowingFeed :one
+SELECT EXISTS (
+    SELECT 1 FROM feed_follows
+    WHERE feed_id = $1 AND user_id = $2
+) AS following;
+
+-- name: SetFeedFollowAlias :one
+UPDATE feed_follows
+SET alias = $3, updated_at = NOW()
+WHERE feed_id = $1 AND user_id = $2
+RETURNING *;
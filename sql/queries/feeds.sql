@@ -30,7 +30,42 @@ UPDATE feeds
 SET last_fetched_at = NOW()
 WHERE id = $1;
 
--- name: GetNextFeedToFetch :one
-SELECT * FROM feeds
-ORDER BY last_fetched_at ASC NULLS FIRST
-LIMIT 1;
+-- name: ClaimNextFeed :one
+-- Leases the feed that has waited longest, skipping feeds another
+-- aggregator holds, and moves it to the back of the queue.
+UPDATE feeds
+SET last_attempted_at = NOW(),
+    leased_by = @owner::text,
+    lease_expires_at = NOW() + make_interval(secs => @lease_seconds::int)
+WHERE id = (
+    SELECT id FROM feeds
+    WHERE lease_expires_at IS NULL OR lease_expires_at < NOW()
+    ORDER BY last_attempted_at ASC NULLS FIRST
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: ReleaseFeedLease :exec
+-- Only the holder can release a lease; once it expires someone else may
+-- hold it.
+UPDATE feeds
+SET leased_by = NULL, lease_expires_at = NULL
+WHERE id = @id AND leased_by = @owner::text;
+
+-- name: RenewFeedLease :execrows
+-- Extends a lease that is still held by owner. No rows means it was lost.
+UPDATE feeds
+SET lease_expires_at = NOW() + make_interval(secs => @lease_seconds::int)
+WHERE id = @id AND leased_by = @owner::text;
+
+-- name: GetFeedQueueLag :one
+-- How far the feed next in line is overdue: how long it has waited since
+-- it was last tried, or since it was added if it never was, less the fetch
+-- interval. Zero when nothing is overdue.
+SELECT GREATEST(
+    COALESCE(EXTRACT(EPOCH FROM NOW() - MIN(COALESCE(last_attempted_at, created_at))), 0) - @interval_seconds::float8,
+    0
+)::float8 AS lag_seconds
+FROM feeds
+WHERE lease_expires_at IS NULL OR lease_expires_at < NOW();
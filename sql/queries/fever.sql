@@ -0,0 +1,99 @@
+-- name: CountFeverItems :one
+SELECT COUNT(*) FROM posts p
+JOIN feed_follows ff ON ff.feed_id = p.feed_id
+LEFT JOIN post_states ps ON ps.post_id = p.id AND ps.user_id = ff.user_id
+WHERE ff.user_id = $1
+  AND COALESCE(ps.hidden, false) = false;
+
+-- name: DeleteFeverKey :execrows
+DELETE FROM fever_keys
+WHERE user_id = $1;
+
+-- name: GetFeverFeeds :many
+SELECT
+    f.seq,
+    COALESCE(ff.alias, f.name) AS name,
+    f.url,
+    f.last_fetched_at
+FROM feed_follows ff
+JOIN feeds f ON ff.feed_id = f.id
+WHERE ff.user_id = $1
+ORDER BY f.seq;
+
+-- name: GetFeverItems :many
+SELECT
+    p.seq,
+    f.seq AS feed_seq,
+    p.title,
+    p.author,
+    p.description,
+    p.url,
+    COALESCE(p.published_at, p.created_at) AS published_at,
+    COALESCE(ps.read, false) AS read,
+    COALESCE(ps.starred, false) AS starred
+FROM posts p
+JOIN feeds f ON p.feed_id = f.id
+JOIN feed_follows ff ON ff.feed_id = f.id
+LEFT JOIN post_states ps ON ps.post_id = p.id AND ps.user_id = ff.user_id
+WHERE ff.user_id = @user_id
+  AND COALESCE(ps.hidden, false) = false
+  AND (sqlc.narg(since_id)::bigint IS NULL OR p.seq > sqlc.narg(since_id))
+  AND (sqlc.narg(max_id)::bigint IS NULL OR p.seq < sqlc.narg(max_id))
+  AND (sqlc.narg(with_ids)::bigint[] IS NULL OR p.seq = ANY(sqlc.narg(with_ids)::bigint[]))
+-- Oldest first when paging forward, newest first when paging back from max_id
+ORDER BY
+    CASE WHEN sqlc.narg(max_id)::bigint IS NULL THEN p.seq END ASC,
+    p.seq DESC
+LIMIT 50;
+
+-- name: GetPostIDBySeq :one
+SELECT id FROM posts
+WHERE seq = $1;
+
+-- name: GetSavedFeverItemIDs :many
+SELECT p.seq FROM posts p
+JOIN post_states ps ON ps.post_id = p.id
+JOIN feed_follows ff ON ff.feed_id = p.feed_id AND ff.user_id = ps.user_id
+WHERE ps.user_id = $1
+  AND ps.starred = true
+  AND ps.hidden = false
+ORDER BY p.seq;
+
+-- name: GetUnreadFeverItemIDs :many
+SELECT p.seq FROM posts p
+JOIN feed_follows ff ON ff.feed_id = p.feed_id
+LEFT JOIN post_states ps ON ps.post_id = p.id AND ps.user_id = ff.user_id
+WHERE ff.user_id = $1
+  AND COALESCE(ps.read, false) = false
+  AND COALESCE(ps.hidden, false) = false
+ORDER BY p.seq;
+
+-- name: GetUserByFeverKey :one
+SELECT users.* FROM users
+JOIN fever_keys ON fever_keys.user_id = users.id
+WHERE fever_keys.key_hash = $1;
+
+-- name: MarkFeverPostsRead :exec
+-- Marks everything fetched up to before as read, in one feed or, with a
+-- NULL feed_seq, in every feed the user follows.
+INSERT INTO post_states (user_id, post_id, read)
+SELECT @user_id::uuid, p.id, true
+FROM posts p
+JOIN feeds f ON p.feed_id = f.id
+JOIN feed_follows ff ON ff.feed_id = f.id AND ff.user_id = @user_id
+WHERE (sqlc.narg(feed_seq)::bigint IS NULL OR f.seq = sqlc.narg(feed_seq))
+  AND p.created_at <= @before
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET read = true,
+    updated_at = NOW();
+
+-- name: SetFeverKey :exec
+INSERT INTO fever_keys (user_id, key_hash)
+VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE
+SET key_hash = EXCLUDED.key_hash;
+
+-- name: UpdateFeverKey :exec
+UPDATE fever_keys
+SET key_hash = $2
+WHERE user_id = $1;
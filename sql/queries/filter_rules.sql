@@ -0,0 +1,29 @@
+-- name: CreateFilterRule :one
+INSERT INTO filter_rules (user_id, feed_id, field, match_type, pattern, action)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: GetFilterRulesForUser :many
+SELECT
+    filter_rules.*,
+    feeds.url AS feed_url
+FROM filter_rules
+LEFT JOIN feeds ON filter_rules.feed_id = feeds.id
+WHERE filter_rules.user_id = $1
+ORDER BY filter_rules.created_at ASC;
+
+-- name: GetFilterRule :one
+SELECT * FROM filter_rules
+WHERE id = $1 AND user_id = $2;
+
+-- name: GetFilterRulesForFeed :many
+SELECT filter_rules.*
+FROM filter_rules
+JOIN feed_follows ON feed_follows.user_id = filter_rules.user_id
+WHERE feed_follows.feed_id = $1
+  AND (filter_rules.feed_id IS NULL OR filter_rules.feed_id = feed_follows.feed_id)
+ORDER BY filter_rules.created_at ASC;
+
+-- name: DeleteFilterRule :execrows
+DELETE FROM filter_rules
+WHERE id = $1 AND user_id = $2;
@@ -0,0 +1,84 @@
+-- name: CreatePodcastEpisodes :exec
+INSERT INTO podcast_episodes (post_id, duration_seconds, season, episode, explicit, image_url)
+SELECT
+    t.post_id,
+    NULLIF(t.duration_seconds, 0),
+    NULLIF(t.season, 0),
+    NULLIF(t.episode, 0),
+    NULLIF(t.explicit, '')::boolean,
+    NULLIF(t.image_url, '')
+FROM unnest(
+    @post_ids::uuid[],
+    @duration_seconds::integer[],
+    @seasons::integer[],
+    @episodes::integer[],
+    @explicits::text[],
+    @image_urls::text[]
+) AS t(post_id, duration_seconds, season, episode, explicit, image_url)
+ON CONFLICT (post_id) DO NOTHING;
+
+-- name: GetEnclosuresForPost :many
+SELECT
+    e.id,
+    e.url,
+    e.type,
+    p.title AS post_title,
+    f.name AS feed_name
+FROM post_enclosures e
+JOIN posts p ON e.post_id = p.id
+JOIN feeds f ON p.feed_id = f.id
+JOIN feed_follows ff ON ff.feed_id = f.id
+WHERE e.post_id = $1 AND ff.user_id = $2
+ORDER BY e.created_at ASC;
+
+-- name: GetEpisodeDownload :one
+SELECT * FROM episode_downloads
+WHERE user_id = $1 AND enclosure_id = $2;
+
+-- name: GetEpisodeDownloadsForUser :many
+SELECT
+    d.id,
+    d.created_at,
+    d.path,
+    d.size,
+    d.sha256,
+    e.url,
+    p.title AS post_title,
+    f.name AS feed_name
+FROM episode_downloads d
+JOIN post_enclosures e ON d.enclosure_id = e.id
+JOIN posts p ON e.post_id = p.id
+JOIN feeds f ON p.feed_id = f.id
+WHERE d.user_id = $1
+ORDER BY d.created_at DESC;
+
+-- name: GetFollowedPostID :one
+-- Finds a post by ID or URL among the feeds the user follows.
+SELECT posts.id FROM posts
+JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+WHERE (posts.id = sqlc.narg('id') OR posts.url = @url)
+  AND feed_follows.user_id = @user_id;
+
+-- name: GetLatestEnclosuresForFeed :many
+SELECT
+    e.id,
+    e.url,
+    e.type,
+    p.title AS post_title,
+    f.name AS feed_name
+FROM post_enclosures e
+JOIN posts p ON e.post_id = p.id
+JOIN feeds f ON p.feed_id = f.id
+WHERE p.feed_id = $1
+ORDER BY p.published_at DESC NULLS LAST
+LIMIT $2;
+
+-- name: SaveEpisodeDownload :one
+INSERT INTO episode_downloads (user_id, enclosure_id, path, size, sha256)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (user_id, enclosure_id) DO UPDATE
+SET path = EXCLUDED.path,
+    size = EXCLUDED.size,
+    sha256 = EXCLUDED.sha256,
+    updated_at = NOW()
+RETURNING *;
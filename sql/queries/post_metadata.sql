@@ -0,0 +1,40 @@
+-- name: CreatePostCategories :exec
+INSERT INTO post_categories (post_id, name)
+SELECT t.post_id, t.name
+FROM unnest(@post_ids::uuid[], @names::text[]) AS t(post_id, name)
+ON CONFLICT (post_id, name) DO NOTHING;
+
+-- name: CreatePostEnclosures :exec
+INSERT INTO post_enclosures (post_id, url, type, length)
+SELECT t.post_id, t.url, NULLIF(t.type, ''), NULLIF(t.length, 0)
+FROM unnest(@post_ids::uuid[], @urls::text[], @types::text[], @lengths::bigint[]) AS t(post_id, url, type, length)
+ON CONFLICT (post_id, url) DO NOTHING;
+
+-- name: CreatePostThumbnails :exec
+INSERT INTO post_thumbnails (post_id, url, width, height)
+SELECT t.post_id, t.url, NULLIF(t.width, 0), NULLIF(t.height, 0)
+FROM unnest(@post_ids::uuid[], @urls::text[], @widths::integer[], @heights::integer[]) AS t(post_id, url, width, height)
+ON CONFLICT (post_id, url) DO NOTHING;
+
+-- name: GetEnclosuresForUser :many
+SELECT
+    e.id,
+    e.url,
+    e.type,
+    e.length,
+    p.id AS post_id,
+    p.title AS post_title,
+    p.published_at,
+    COALESCE(ff.alias, f.name) AS feed_name,
+    pe.duration_seconds,
+    pe.season,
+    pe.episode,
+    pe.explicit
+FROM post_enclosures e
+JOIN posts p ON e.post_id = p.id
+JOIN feeds f ON p.feed_id = f.id
+JOIN feed_follows ff ON ff.feed_id = f.id
+LEFT JOIN podcast_episodes pe ON pe.post_id = p.id
+WHERE ff.user_id = $1
+ORDER BY p.published_at DESC NULLS LAST
+LIMIT $2;
@@ -0,0 +1,26 @@
+-- name: UpsertPostState :one
+-- Flags are only ever switched on here, so applying the same rule twice is harmless.
+INSERT INTO post_states (user_id, post_id, read, starred, hidden)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET read = post_states.read OR EXCLUDED.read,
+    starred = post_states.starred OR EXCLUDED.starred,
+    hidden = post_states.hidden OR EXCLUDED.hidden,
+    updated_at = NOW()
+RETURNING *;
+
+-- name: SetPostRead :one
+INSERT INTO post_states (user_id, post_id, read)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET read = EXCLUDED.read,
+    updated_at = NOW()
+RETURNING *;
+
+-- name: SetPostStarred :one
+INSERT INTO post_states (user_id, post_id, starred)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET starred = EXCLUDED.starred,
+    updated_at = NOW()
+RETURNING *;
@@ -1,6 +1,27 @@
 -- name: CreatePost :one
-INSERT INTO posts (title, url, description, published_at, feed_id)
-VALUES ($1, $2, $3, $4, $5)
+INSERT INTO posts (title, url, description, published_at, feed_id, author)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: CreatePosts :many
+INSERT INTO posts (title, url, description, published_at, feed_id, author, comments_url)
+SELECT
+    t.title,
+    t.url,
+    NULLIF(t.description, ''),
+    NULLIF(t.published_at, '')::timestamp,
+    @feed_id::uuid,
+    NULLIF(t.author, ''),
+    NULLIF(t.comments_url, '')
+FROM unnest(
+    @titles::text[],
+    @urls::text[],
+    @descriptions::text[],
+    @published_ats::text[],
+    @authors::text[],
+    @comments_urls::text[]
+) AS t(title, url, description, published_at, author, comments_url)
+ON CONFLICT (url) DO NOTHING
 RETURNING *;
 
 -- name: GetPostsForUser :many
@@ -10,9 +31,36 @@ SELECT
     p.url, 
     p.description, 
     p.published_at, 
-    p.feed_id
+    p.feed_id,
+    p.author,
+    p.comments_url,
+    COALESCE(ff.alias, f.name) AS feed_name,
+    COALESCE(ps.read, false) AS read,
+    COALESCE(ps.starred, false) AS starred,
+    ARRAY(SELECT pc.name FROM post_categories pc WHERE pc.post_id = p.id ORDER BY pc.name)::text[] AS tags,
+    th.url AS thumbnail_url,
+    th.width AS thumbnail_width,
+    th.height AS thumbnail_height
 FROM posts p
 JOIN feeds f ON p.feed_id = f.id
-WHERE f.user_id = $1
+JOIN feed_follows ff ON ff.feed_id = f.id
+LEFT JOIN post_states ps ON ps.post_id = p.id AND ps.user_id = ff.user_id
+LEFT JOIN LATERAL (
+    SELECT t.url, t.width, t.height FROM post_thumbnails t
+    WHERE t.post_id = p.id
+    ORDER BY t.width DESC NULLS LAST, t.created_at ASC
+    LIMIT 1
+) th ON true
+WHERE ff.user_id = @user_id
+  AND COALESCE(ps.hidden, false) = false
+  AND (sqlc.narg(tag)::text IS NULL OR EXISTS (
+      SELECT 1 FROM post_categories pc
+      WHERE pc.post_id = p.id AND lower(pc.name) = lower(sqlc.narg(tag))
+  ))
+  AND (sqlc.narg(author)::text IS NULL OR p.author ILIKE '%' || sqlc.narg(author) || '%')
+  AND (sqlc.narg(feed_id)::uuid IS NULL OR p.feed_id = sqlc.narg(feed_id))
+  AND (sqlc.narg(search)::text IS NULL
+       OR p.title ILIKE '%' || sqlc.narg(search) || '%'
+       OR p.description ILIKE '%' || sqlc.narg(search) || '%')
 ORDER BY p.published_at DESC
-LIMIT $2;
+LIMIT sqlc.arg(row_limit) OFFSET sqlc.arg(row_offset);
@@ -0,0 +1,17 @@
+-- name: CreateSession :one
+INSERT INTO sessions (user_id, token_hash, expires_at)
+VALUES ($1, $2, $3)
+RETURNING *;
+
+-- name: DeleteExpiredSessions :exec
+DELETE FROM sessions
+WHERE expires_at <= NOW();
+
+-- name: DeleteSession :exec
+DELETE FROM sessions
+WHERE token_hash = $1;
+
+-- name: GetUserBySession :one
+SELECT users.* FROM users
+JOIN sessions ON sessions.user_id = users.id
+WHERE sessions.token_hash = $1 AND sessions.expires_at > NOW();
@@ -1,10 +1,11 @@
 -- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, name)
+INSERT INTO users (id, created_at, updated_at, name, password_hash)
 VALUES (
     $1,
     $2,
     $3,
-    $4
+    $4,
+    $5
 )
 RETURNING *;
 
@@ -16,4 +17,17 @@ WHERE name = $1;
 DELETE FROM users;
 
 -- name: GetAllUsers :many
-SELECT * FROM users;
\ No newline at end of file
+SELECT * FROM users;
+
+-- name: SetUserPassword :exec
+UPDATE users
+SET password_hash = $2, updated_at = NOW()
+WHERE id = $1;
+
+-- name: ClaimUser :one
+-- Sets the password of a user created before passwords existed. Users who
+-- already have one are left alone.
+UPDATE users
+SET password_hash = $2, updated_at = NOW()
+WHERE name = $1 AND password_hash IS NULL
+RETURNING *;
@@ -0,0 +1,112 @@
+-- name: CreateWebhook :one
+INSERT INTO webhooks (user_id, url, secret, feed_id, category, keyword, template, content_type)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING *;
+
+-- name: GetWebhooksForUser :many
+SELECT
+    webhooks.*,
+    feeds.url AS feed_url
+FROM webhooks
+LEFT JOIN feeds ON webhooks.feed_id = feeds.id
+WHERE webhooks.user_id = $1
+ORDER BY webhooks.created_at ASC;
+
+-- name: DeleteWebhook :execrows
+DELETE FROM webhooks
+WHERE id = $1 AND user_id = $2;
+
+-- name: EnqueueWebhookDeliveries :execrows
+-- Queues a delivery for every webhook that matches one of the new posts,
+-- skipping posts its owner doesn't follow or has hidden with a filter.
+INSERT INTO webhook_deliveries (webhook_id, post_id)
+SELECT w.id, p.id
+FROM posts p
+JOIN feed_follows ff ON ff.feed_id = p.feed_id
+JOIN webhooks w ON w.user_id = ff.user_id
+LEFT JOIN post_states ps ON ps.post_id = p.id AND ps.user_id = w.user_id
+WHERE p.id = ANY(@post_ids::uuid[])
+  AND COALESCE(ps.hidden, false) = false
+  AND (w.feed_id IS NULL OR w.feed_id = p.feed_id)
+  AND (w.category IS NULL OR EXISTS (
+      SELECT 1 FROM post_categories pc
+      WHERE pc.post_id = p.id AND lower(pc.name) = lower(w.category)
+  ))
+  AND (w.keyword IS NULL
+       OR position(lower(w.keyword) IN lower(p.title)) > 0
+       OR position(lower(w.keyword) IN lower(p.description)) > 0)
+ON CONFLICT (webhook_id, post_id) DO NOTHING;
+
+-- name: ClaimWebhookDeliveries :many
+-- Due deliveries, pushed back by claim_seconds so another aggregator
+-- doesn't send them too. Sending records the real outcome.
+WITH claimed AS (
+    UPDATE webhook_deliveries
+    SET next_attempt_at = NOW() + make_interval(secs => @claim_seconds::int)
+    WHERE webhook_deliveries.id IN (
+        SELECT id FROM webhook_deliveries
+        WHERE status = 'pending' AND next_attempt_at <= NOW()
+        ORDER BY next_attempt_at
+        LIMIT @limit
+        FOR UPDATE SKIP LOCKED
+    )
+    RETURNING webhook_deliveries.id, webhook_deliveries.created_at, webhook_deliveries.attempts, webhook_deliveries.webhook_id, webhook_deliveries.post_id
+)
+SELECT
+    d.id,
+    d.attempts,
+    w.url AS webhook_url,
+    w.secret,
+    w.template,
+    w.content_type,
+    p.id AS post_id,
+    p.title,
+    p.url AS post_url,
+    p.description,
+    p.author,
+    p.published_at,
+    COALESCE(ff.alias, f.name) AS feed_name,
+    f.url AS feed_url
+FROM claimed d
+JOIN webhooks w ON w.id = d.webhook_id
+JOIN posts p ON p.id = d.post_id
+JOIN feeds f ON f.id = p.feed_id
+LEFT JOIN feed_follows ff ON ff.feed_id = f.id AND ff.user_id = w.user_id
+ORDER BY d.created_at;
+
+-- name: MarkWebhookDelivered :exec
+UPDATE webhook_deliveries
+SET status = 'delivered',
+    attempts = attempts + 1,
+    response_code = $2,
+    last_error = NULL,
+    delivered_at = NOW()
+WHERE id = $1;
+
+-- name: RecordWebhookFailure :exec
+-- Schedules another attempt, or gives up once max_attempts is reached.
+UPDATE webhook_deliveries
+SET attempts = attempts + 1,
+    response_code = @response_code,
+    last_error = @last_error,
+    status = CASE WHEN attempts + 1 >= @max_attempts::int THEN 'failed' ELSE 'pending' END,
+    next_attempt_at = NOW() + make_interval(secs => @retry_seconds::int)
+WHERE id = @id;
+
+-- name: GetWebhookDeliveriesForUser :many
+SELECT
+    d.id,
+    d.created_at,
+    w.url AS webhook_url,
+    p.title AS post_title,
+    d.status,
+    d.attempts,
+    d.response_code,
+    d.last_error,
+    d.delivered_at
+FROM webhook_deliveries d
+JOIN webhooks w ON w.id = d.webhook_id
+JOIN posts p ON p.id = d.post_id
+WHERE w.user_id = $1
+ORDER BY d.created_at DESC
+LIMIT $2;
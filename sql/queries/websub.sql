@@ -0,0 +1,59 @@
+-- name: ActivateWebSubSubscription :exec
+UPDATE websub_subscriptions
+SET state = 'active',
+    lease_expires_at = NOW() + make_interval(secs => @lease_seconds::int),
+    last_error = NULL,
+    updated_at = NOW()
+WHERE id = @id;
+
+-- name: DenyWebSubSubscription :exec
+UPDATE websub_subscriptions
+SET state = 'denied', lease_expires_at = NULL, last_error = $2, updated_at = NOW()
+WHERE id = $1;
+
+-- name: FailWebSubSubscription :exec
+UPDATE websub_subscriptions
+SET state = 'failed', last_error = $2, updated_at = NOW()
+WHERE id = $1;
+
+-- name: GetWebSubSubscription :one
+SELECT * FROM websub_subscriptions
+WHERE id = $1;
+
+-- name: GetWebSubSubscriptionForFeed :one
+SELECT * FROM websub_subscriptions
+WHERE feed_id = $1;
+
+-- name: GetWebSubSubscriptions :many
+SELECT
+    ws.id,
+    f.name AS feed_name,
+    f.url AS feed_url,
+    ws.hub_url,
+    ws.state,
+    ws.lease_expires_at,
+    ws.last_error
+FROM websub_subscriptions ws
+JOIN feeds f ON f.id = ws.feed_id
+ORDER BY f.name;
+
+-- name: GetWebSubSubscriptionsToRenew :many
+-- Active leases that end within a day, requests the hub never confirmed
+-- after an hour, and denied requests after a day, since hubs may refuse
+-- only for a while.
+SELECT * FROM websub_subscriptions
+WHERE (state = 'active' AND lease_expires_at < NOW() + INTERVAL '1 day')
+   OR (state IN ('pending', 'failed') AND updated_at < NOW() - INTERVAL '1 hour')
+   OR (state = 'denied' AND updated_at < NOW() - INTERVAL '1 day')
+ORDER BY updated_at;
+
+-- name: RequestWebSubSubscription :one
+INSERT INTO websub_subscriptions (feed_id, hub_url, topic_url, secret)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (feed_id) DO UPDATE
+SET hub_url = EXCLUDED.hub_url,
+    topic_url = EXCLUDED.topic_url,
+    secret = EXCLUDED.secret,
+    state = 'pending',
+    updated_at = NOW()
+RETURNING *;
@@ -0,0 +1,31 @@
+-- +goose Up
+ALTER TABLE posts ADD COLUMN author TEXT;
+
+CREATE TABLE post_states (
+    id uuid primary key default gen_random_uuid(),
+    created_at timestamp not null default now(),
+    updated_at timestamp not null default now(),
+    user_id uuid not null references users(id) on delete cascade,
+    post_id uuid not null references posts(id) on delete cascade,
+    read boolean not null default false,
+    starred boolean not null default false,
+    hidden boolean not null default false,
+    unique (user_id, post_id)
+);
+
+CREATE TABLE filter_rules (
+    id uuid primary key default gen_random_uuid(),
+    created_at timestamp not null default now(),
+    updated_at timestamp not null default now(),
+    user_id uuid not null references users(id) on delete cascade,
+    feed_id uuid references feeds(id) on delete cascade,
+    field text not null,
+    match_type text not null,
+    pattern text not null,
+    action text not null
+);
+
+-- +goose Down
+DROP TABLE filter_rules;
+DROP TABLE post_states;
+ALTER TABLE posts DROP COLUMN author;
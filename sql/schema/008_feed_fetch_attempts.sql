@@ -0,0 +1,6 @@
+-- +goose Up
+ALTER TABLE feeds ADD COLUMN last_attempted_at TIMESTAMPTZ;
+UPDATE feeds SET last_attempted_at = last_fetched_at;
+
+-- +goose Down
+ALTER TABLE feeds DROP COLUMN last_attempted_at;
@@ -0,0 +1,26 @@
+-- +goose Up
+ALTER TABLE posts ADD COLUMN comments_url TEXT;
+
+CREATE TABLE post_categories (
+    id uuid primary key default gen_random_uuid(),
+    created_at timestamp not null default now(),
+    post_id uuid not null references posts(id) on delete cascade,
+    name text not null,
+    unique (post_id, name)
+);
+
+CREATE TABLE post_enclosures (
+    id uuid primary key default gen_random_uuid(),
+    created_at timestamp not null default now(),
+    updated_at timestamp not null default now(),
+    post_id uuid not null references posts(id) on delete cascade,
+    url text not null,
+    type text,
+    length bigint,
+    unique (post_id, url)
+);
+
+-- +goose Down
+DROP TABLE post_enclosures;
+DROP TABLE post_categories;
+ALTER TABLE posts DROP COLUMN comments_url;
@@ -0,0 +1,28 @@
+-- +goose Up
+CREATE TABLE podcast_episodes (
+    id uuid primary key default gen_random_uuid(),
+    created_at timestamp not null default now(),
+    updated_at timestamp not null default now(),
+    post_id uuid not null unique references posts(id) on delete cascade,
+    duration_seconds integer,
+    season integer,
+    episode integer,
+    explicit boolean,
+    image_url text
+);
+
+CREATE TABLE episode_downloads (
+    id uuid primary key default gen_random_uuid(),
+    created_at timestamp not null default now(),
+    updated_at timestamp not null default now(),
+    user_id uuid not null references users(id) on delete cascade,
+    enclosure_id uuid not null references post_enclosures(id) on delete cascade,
+    path text not null,
+    size bigint not null,
+    sha256 text not null,
+    unique (user_id, enclosure_id)
+);
+
+-- +goose Down
+DROP TABLE episode_downloads;
+DROP TABLE podcast_episodes;
@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE post_thumbnails (
+    id uuid primary key default gen_random_uuid(),
+    created_at timestamp not null default now(),
+    post_id uuid not null references posts(id) on delete cascade,
+    url text not null,
+    width integer,
+    height integer,
+    unique (post_id, url)
+);
+
+-- +goose Down
+DROP TABLE post_thumbnails;
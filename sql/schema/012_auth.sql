@@ -0,0 +1,15 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN password_hash TEXT;
+
+CREATE TABLE api_tokens (
+    id uuid primary key default gen_random_uuid(),
+    created_at timestamp not null default now(),
+    user_id uuid not null references users(id) on delete cascade,
+    name text not null,
+    token_hash text not null unique,
+    last_used_at timestamptz
+);
+
+-- +goose Down
+DROP TABLE api_tokens;
+ALTER TABLE users DROP COLUMN password_hash;
@@ -0,0 +1,11 @@
+-- +goose Up
+CREATE TABLE sessions (
+    id uuid primary key default gen_random_uuid(),
+    created_at timestamp not null default now(),
+    user_id uuid not null references users(id) on delete cascade,
+    token_hash text not null unique,
+    expires_at timestamptz not null
+);
+
+-- +goose Down
+DROP TABLE sessions;
@@ -0,0 +1,15 @@
+-- +goose Up
+-- Fever clients identify feeds and items by integer
+ALTER TABLE feeds ADD COLUMN seq BIGSERIAL UNIQUE;
+ALTER TABLE posts ADD COLUMN seq BIGSERIAL UNIQUE;
+
+CREATE TABLE fever_keys (
+    user_id uuid primary key references users(id) on delete cascade,
+    created_at timestamp not null default now(),
+    api_key text not null unique
+);
+
+-- +goose Down
+DROP TABLE fever_keys;
+ALTER TABLE posts DROP COLUMN seq;
+ALTER TABLE feeds DROP COLUMN seq;
@@ -0,0 +1,18 @@
+-- +goose Up
+-- state is pending until the hub verifies the request, then active until
+-- the lease runs out; denied and failed subscriptions keep last_error.
+CREATE TABLE websub_subscriptions (
+    id uuid primary key default gen_random_uuid(),
+    created_at timestamp not null default now(),
+    updated_at timestamp not null default now(),
+    feed_id uuid not null unique references feeds(id) on delete cascade,
+    hub_url text not null,
+    topic_url text not null,
+    secret text not null,
+    state text not null default 'pending',
+    lease_expires_at timestamptz,
+    last_error text
+);
+
+-- +goose Down
+DROP TABLE websub_subscriptions;
@@ -0,0 +1,36 @@
+-- +goose Up
+-- A NULL feed_id, category or keyword matches every post.
+CREATE TABLE webhooks (
+    id uuid primary key default gen_random_uuid(),
+    created_at timestamp not null default now(),
+    updated_at timestamp not null default now(),
+    user_id uuid not null references users(id) on delete cascade,
+    url text not null,
+    secret text not null,
+    feed_id uuid references feeds(id) on delete cascade,
+    category text,
+    keyword text,
+    template text
+);
+
+-- One row per webhook and post; status is pending, delivered or failed.
+CREATE TABLE webhook_deliveries (
+    id uuid primary key default gen_random_uuid(),
+    created_at timestamp not null default now(),
+    webhook_id uuid not null references webhooks(id) on delete cascade,
+    post_id uuid not null references posts(id) on delete cascade,
+    status text not null default 'pending',
+    attempts int not null default 0,
+    response_code int,
+    last_error text,
+    next_attempt_at timestamptz not null default now(),
+    delivered_at timestamptz,
+    unique (webhook_id, post_id)
+);
+
+CREATE INDEX webhook_deliveries_due ON webhook_deliveries (next_attempt_at)
+WHERE status = 'pending';
+
+-- +goose Down
+DROP TABLE webhook_deliveries;
+DROP TABLE webhooks;
@@ -0,0 +1,12 @@
+-- +goose Up
+-- Users who asked for email digests. last_digest_at marks where the next
+-- digest starts.
+CREATE TABLE digests (
+    user_id uuid primary key references users(id) on delete cascade,
+    created_at timestamp not null default now(),
+    email text not null,
+    last_digest_at timestamptz
+);
+
+-- +goose Down
+DROP TABLE digests;
@@ -0,0 +1,10 @@
+-- +goose Up
+-- An aggregator leases a feed while fetching it, so aggregators sharing
+-- the database never fetch the same feed at once. A lease that outlives
+-- its aggregator simply expires.
+ALTER TABLE feeds ADD COLUMN leased_by TEXT;
+ALTER TABLE feeds ADD COLUMN lease_expires_at TIMESTAMPTZ;
+
+-- +goose Down
+ALTER TABLE feeds DROP COLUMN lease_expires_at;
+ALTER TABLE feeds DROP COLUMN leased_by;
@@ -0,0 +1,11 @@
+-- +goose Up
+-- Fever keys are stored hashed, like API tokens. Existing keys are hashed
+-- in place, so readers stay logged in.
+ALTER TABLE fever_keys RENAME COLUMN api_key TO key_hash;
+UPDATE fever_keys SET key_hash = encode(sha256(convert_to(key_hash, 'UTF8')), 'hex');
+
+-- +goose Down
+-- The keys can't be recovered from their hashes, so Fever has to be turned
+-- on again after going back.
+DELETE FROM fever_keys;
+ALTER TABLE fever_keys RENAME COLUMN key_hash TO api_key;
@@ -0,0 +1,7 @@
+-- +goose Up
+-- The Content-Type header sent with a webhook's requests. NULL means
+-- application/json for the default payload and no header for a template.
+ALTER TABLE webhooks ADD COLUMN content_type text;
+
+-- +goose Down
+ALTER TABLE webhooks DROP COLUMN content_type;
@@ -0,0 +1,24 @@
+-- +goose Up
+-- The posts each user's digests have included, so a post stored by a
+-- transaction still open while a digest was sent goes in the next one
+-- rather than falling behind last_digest_at. last_digest_at now only says
+-- when the next digest is due.
+CREATE TABLE digest_posts (
+    id uuid primary key default gen_random_uuid(),
+    created_at timestamp not null default now(),
+    user_id uuid not null references digests(user_id) on delete cascade,
+    post_id uuid not null references posts(id) on delete cascade,
+    unique (user_id, post_id)
+);
+
+-- Posts the old cutoff already covered aren't sent again
+INSERT INTO digest_posts (user_id, post_id)
+SELECT d.user_id, p.id
+FROM digests d
+JOIN feed_follows ff ON ff.user_id = d.user_id
+JOIN posts p ON p.feed_id = ff.feed_id
+WHERE p.created_at > d.created_at - INTERVAL '1 day'
+  AND p.created_at <= d.last_digest_at;
+
+-- +goose Down
+DROP TABLE digest_posts;